@@ -0,0 +1,54 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import "net"
+
+// bogonNets lists the IPv4 ranges that aren't globally routable: RFC 1918 private space, shared
+// (CGNAT) space, loopback, link-local, documentation, benchmarking, multicast and reserved ranges.
+var bogonNets = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "This" network.
+		"10.0.0.0/8",      // RFC 1918 private.
+		"100.64.0.0/10",   // RFC 6598 shared address space (CGNAT).
+		"127.0.0.0/8",     // Loopback.
+		"169.254.0.0/16",  // Link-local.
+		"172.16.0.0/12",   // RFC 1918 private.
+		"192.0.0.0/24",    // IETF protocol assignments.
+		"192.0.2.0/24",    // TEST-NET-1 documentation.
+		"192.168.0.0/16",  // RFC 1918 private.
+		"198.18.0.0/15",   // Benchmarking.
+		"198.51.100.0/24", // TEST-NET-2 documentation.
+		"203.0.113.0/24",  // TEST-NET-3 documentation.
+		"224.0.0.0/4",     // Multicast.
+		"240.0.0.0/4",     // Reserved, including broadcast.
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return
+}()
+
+// IsBogon reports whether ip is a private (RFC 1918) or otherwise non-globally-routable IPv4 address.
+// Such hops typically belong to internal networks and can be filtered or masked in published output.
+func IsBogon(ip net.IP) bool {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false // Only IPv4 ranges are known.
+	}
+	for _, n := range bogonNets {
+		if n.Contains(ip4) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"sort"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/icmp"
+)
+
+// bpfMaxIDs bounds the number of IDs matched by the filter, keeping jump offsets within 8 bits.
+const bpfMaxIDs = 100
+
+// filterProgram assembles a classic BPF program for a raw IPv4 ICMP socket that only accepts Echo Replies
+// carrying one of ids, and Time Exceeded messages quoting an Echo Request carrying one of them. Raw sockets
+// hand the filter the packet starting at the IP header.
+func filterProgram(ids []int) []bpf.Instruction {
+	matchIDs := func(off uint32) []bpf.Instruction {
+		ins := []bpf.Instruction{bpf.LoadIndirect{Off: off, Size: 2}} // Load the ICMP ID.
+		for _, id := range ids {
+			ins = append(ins, bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(id)}) // Accept our IDs; patched below.
+		}
+		return append(ins, bpf.RetConstant{Val: 0}) // Drop other IDs.
+	}
+	// Echo Reply: the ID follows type, code and checksum.
+	echo := matchIDs(4)
+	// Time Exceeded: the quoted IP header starts after the 8-byte ICMP header; unless it carries options,
+	// the quoted ICMP ID sits at 8 + 20 + 4. Packets with options are accepted and filtered in userspace.
+	exceeded := append([]bpf.Instruction{
+		bpf.LoadIndirect{Off: 8, Size: 1},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0x0f},
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: 5}, // Accept quoted headers with options; patched below.
+	}, matchIDs(32)...)
+
+	prog := []bpf.Instruction{
+		bpf.LoadMemShift{Off: 0},                                                 // X = IP header length.
+		bpf.LoadIndirect{Off: 0, Size: 1},                                        // Load the ICMP type.
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0, SkipTrue: 2},                     // Echo Reply.
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 11, SkipTrue: uint8(1 + len(echo))}, // Time Exceeded.
+		bpf.RetConstant{Val: 0},                                                  // Drop everything else.
+	}
+	prog = append(append(prog, echo...), exceeded...)
+	accept := len(prog)
+	prog = append(prog, bpf.RetConstant{Val: 0x40000}) // Accept the whole packet.
+	// Point the accepting jumps at the final instruction.
+	for i, in := range prog[:accept] {
+		if j, ok := in.(bpf.JumpIf); ok && i > 3 {
+			j.SkipTrue = uint8(accept - i - 1)
+			prog[i] = j
+		}
+	}
+	return prog
+}
+
+// filter attaches a BPF program matching the owned IDs to conn, so the kernel drops unrelated ICMP
+// traffic instead of waking the read loop for it. Failures, e.g. on platforms without socket filters,
+// are logged and leave filtering to userspace.
+func (p *packet) filter(conn *icmp.PacketConn) {
+	if !p.bpf || conn == nil {
+		return // Filtering disabled or not listening.
+	}
+	if p.v6 {
+		p.debug("filter() no ICMPv6 program, filtering in userspace")
+		return
+	}
+	p.mu.Lock() // Lock for thread-safe set access.
+	ids := make([]int, 0, len(p.ids))
+	for id := range p.ids {
+		ids = append(ids, id)
+	}
+	p.mu.Unlock() // Unlock after set access.
+	if len(ids) > bpfMaxIDs {
+		p.debug("filter() too many ids: %d, filtering in userspace", len(ids))
+		return
+	}
+	sort.Ints(ids)
+	raw, err := bpf.Assemble(filterProgram(ids))
+	if err == nil {
+		err = conn.IPv4PacketConn().SetBPF(raw)
+	}
+	if err != nil {
+		p.debug("filter() err: %v", err) // Log and keep filtering in userspace.
+		return
+	}
+	p.trace("filter() ids: %v", ids)
+}
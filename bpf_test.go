@@ -0,0 +1,60 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestFilterProgram(t *testing.T) {
+	vm, err := bpf.NewVM(filterProgram([]int{7, 9}))
+	if err != nil {
+		t.Fatalf("NewVM() error: %v", err)
+	}
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+	packet := func(typ icmp.Type, body icmp.MessageBody) []byte {
+		msg, _ := (&icmp.Message{Type: typ, Body: body}).Marshal(nil)
+		return append(ip4Header(src, dst, 64, len(msg)), msg...)
+	}
+	exceeded := func(id int) []byte {
+		echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: 1}}).Marshal(nil)
+		quoted := append(ip4Header(dst, src, 1, len(echo)), echo[:8]...)
+		return packet(ipv4.ICMPTypeTimeExceeded, &icmp.TimeExceeded{Data: quoted})
+	}
+	tests := []struct {
+		name   string
+		packet []byte
+		accept bool
+	}{
+		{"own echo reply", packet(ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: 9, Seq: 1}), true},
+		{"foreign echo reply", packet(ipv4.ICMPTypeEchoReply, &icmp.Echo{ID: 8, Seq: 1}), false},
+		{"echo request", packet(ipv4.ICMPTypeEcho, &icmp.Echo{ID: 7, Seq: 1}), false},
+		{"own time exceeded", exceeded(7), true},
+		{"foreign time exceeded", exceeded(8), false},
+		{"destination unreachable", packet(ipv4.ICMPTypeDestinationUnreachable, &icmp.DstUnreach{Data: make([]byte, 28)}), false},
+	}
+	for _, tt := range tests {
+		n, err := vm.Run(tt.packet)
+		if err != nil {
+			t.Fatalf("%s: Run() error: %v", tt.name, err)
+		}
+		if accepted := n > 0; accepted != tt.accept {
+			t.Errorf("%s: accepted = %v; want %v", tt.name, accepted, tt.accept)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// paused is set while probing is paused with the p key
+var paused int32
+
+// showPaths lists every router seen at multi-path hops, toggled with the a key
+var showPaths bool
+
+// keyHelp is shown below the table
+const keyHelp = "Keys: q quit  p pause  r reset  s sort  a paths"
+
+// rawTerminal puts stdin into raw mode so single key presses can be read, returning a function
+// restoring the previous mode. It does nothing if stdin isn't a terminal.
+func rawTerminal() (restore func()) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return func() {}
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return func() {}
+	}
+	return func() { _ = term.Restore(fd, state) }
+}
+
+// readKeys handles key presses until stdin is closed: q (or Ctrl-C) quits, p pauses or resumes probing,
+// r resets the hop counters, s cycles the sort order and a toggles the per-hop address lists
+func readKeys(tr interface {
+	Stop()
+	Pause()
+	Resume()
+}) {
+	buf := make([]byte, 1)
+	for {
+		if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+			return
+		}
+		switch buf[0] {
+		case 'q', 'Q', 3: // 3 is Ctrl-C, which raw mode delivers as a byte instead of SIGINT
+			tr.Stop()
+			return
+		case 'p', 'P':
+			if atomic.LoadInt32(&paused) == 0 {
+				atomic.StoreInt32(&paused, 1)
+				tr.Pause() // Probes in flight still finish
+			} else {
+				atomic.StoreInt32(&paused, 0)
+				tr.Resume()
+			}
+			printPackets()
+		case 'r', 'R':
+			resetHops()
+			printPackets()
+		case 's', 'S':
+			hopsMu.Lock()
+			sortBy = nextSort(sortBy)
+			hopsMu.Unlock()
+			printPackets()
+		case 'a', 'A':
+			hopsMu.Lock()
+			showPaths = !showPaths
+			hopsMu.Unlock()
+			printPackets()
+		}
+	}
+}
+
+// isPaused reports whether probing is paused
+func isPaused() bool { return atomic.LoadInt32(&paused) == 1 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -10,8 +11,7 @@ func prints(ip4 string) {
 	print1()
 	print2(ip4)
 	fmt.Println()
-	print3()
-	print4()
+	fmt.Print("\0337") // save the cursor below the header, where printPackets redraws the table
 }
 
 func print1() {
@@ -39,32 +39,176 @@ func print2(ip4 string) {
 	fmt.Printf("\033[2K\r%s%s%s\n", left, spaces, right)
 }
 
-// ***Packets*** **********Pings**********
-//
-// Loss%   Sent   Last   Avg   Best   Worst
-// 12.2%  99999  999.0 999.0  999.0   999.0
-func print3() {
-	text := "Packets          Pings          "
-	terminalWidth := getTerminalWidth()
-	textWidth := len(text)
-	padding := terminalWidth - textWidth
-	if padding < 0 {
-		padding = 0
+// column is a statistics column of the hop table
+type column struct {
+	title string            // label, right-aligned over the values
+	width int               // width including the separating space
+	value func(*hop) string // value shown for a hop
+}
+
+// columns of the hop table, the first two under "Packets" and the rest under "Pings". Terminals too
+// narrow for the whole table lose columns from the right
+var columns = []column{
+	{"Loss%", 7, func(h *hop) string { return fmt.Sprintf("%d%%", h.Loss) }},
+	{"Sent", 7, func(h *hop) string { return fmt.Sprint(h.Sent) }},
+	{"Last", 7, func(h *hop) string { return fmt.Sprint(h.Last) }},
+	{"Avg", 6, func(h *hop) string { return fmt.Sprint(h.Avg) }},
+	{"Best", 7, func(h *hop) string { return fmt.Sprint(h.Best) }},
+	{"Worst", 8, func(h *hop) string { return fmt.Sprint(h.Worst) }},
+	{"StDev", 7, func(h *hop) string { return fmt.Sprint(h.StDev) }},
+}
+
+// Widths of the hop table
+const (
+	ttlWidth      = 5  // "%3d. " in front of the host
+	minHostWidth  = 16 // narrowest host column before columns are dropped
+	fullHostWidth = 30 // host column when the output isn't a terminal
+	packetColumns = 2  // columns under the "Packets" label
+)
+
+// layout fits the hop table into a terminal width columns wide, returning the width of the host column and
+// the statistics columns that fit. The host column takes up the space the columns leave
+func layout(width int) (host int, cols []column) {
+	cols = columns
+	if width <= 0 {
+		return fullHostWidth, cols // Not a terminal, nothing to fit
 	}
-	spaces := strings.Repeat(" ", padding)
-	fmt.Printf("\033[2K\r%s%s          %s          \n", spaces, boldText("Packets"), boldText("Pings"))
+	for len(cols) > 1 && ttlWidth+minHostWidth+columnsWidth(cols) >= width {
+		cols = cols[:len(cols)-1]
+	}
+	// Leave the last cell free, so a full line doesn't wrap on terminals that wrap eagerly
+	return max(width-1-ttlWidth-columnsWidth(cols), minHostWidth), cols
 }
 
-func print4() {
-	fmt.Println(" \033[1mHost\033[0m")
+// columnsWidth returns the total width of cols
+func columnsWidth(cols []column) (width int) {
+	for _, c := range cols {
+		width += c.width
+	}
+	return width
 }
 
-//	Packets                Pings
-//
-// Loss%   Sent   Last   Avg   Best   Worst
-// 12.2%  99999  999.0 999.0  999.0   999.0
+// fit cuts text to at most width bytes, marking a cut with "..."
+func fit(text string, width int) string {
+	if width <= 0 || len(text) <= width {
+		return text
+	}
+	if width <= 3 {
+		return text[:width]
+	}
+	return text[:width-3] + "..."
+}
+
+// printPackets redraws the hop table in place below the header, laid out for the current terminal width,
+// so it refreshes on every pong without scrolling
 func printPackets() {
+	hopsMu.Lock()
+	defer hopsMu.Unlock()
+	width := getTerminalWidth()
+	host, cols := layout(width)
+	var sb strings.Builder
+	sb.WriteString("\0338\033[J") // restore the cursor below the header and clear the old table
 
+	// Labels, "Packets" and "Pings" above the column titles
+	groups := strings.Repeat(" ", ttlWidth+host)
+	split := min(packetColumns, len(cols))
+	groups += fmt.Sprintf("%*s", columnsWidth(cols[:split]), "Packets")
+	if split < len(cols) {
+		groups += fmt.Sprintf("%*s", columnsWidth(cols[split:]), "Pings")
+	}
+	titles := fmt.Sprintf(" %-*s", ttlWidth+host-1, "Host")
+	for _, c := range cols {
+		titles += fmt.Sprintf("%*s", c.width, c.title)
+	}
+	sb.WriteString(boldText(fit(groups, width)) + "\r\n")
+	sb.WriteString(boldText(fit(titles, width)) + "\r\n")
+
+	var rows []hop
+	for i := 1; i < len(hops); i++ {
+		if hops[i].Sent > 0 {
+			rows = append(rows, hops[i])
+		}
+	}
+	sortHops(rows, sortBy)
+	for _, h := range rows {
+		addr := h.Addr
+		if addr == "" {
+			addr = "???"
+		} else if h.Host != "" {
+			addr = fmt.Sprintf("%s (%s)", h.Host, h.Addr)
+		}
+		paths := h.paths()
+		if len(paths) > 1 {
+			addr = fmt.Sprintf("%s (%d paths)", addr, len(paths))
+		}
+		line := fmt.Sprintf("%3d. %-*s", h.TTL, host, fit(addr, host))
+		for _, c := range cols {
+			line += fmt.Sprintf("%*s", c.width, c.value(&h))
+		}
+		sb.WriteString(fit(line, width) + "\r\n")
+		if showPaths && len(paths) > 1 {
+			for _, p := range paths {
+				// every router seen at this hop with its reply count
+				line := fmt.Sprintf("%*s%-*s %6d", ttlWidth, "", host, fit(p, host), h.Addrs[p])
+				sb.WriteString(fit(line, width) + "\r\n")
+			}
+		}
+	}
+	status := keyHelp
+	if sortBy != sortTTL {
+		status += "  [sorted by " + sortBy + "]"
+	}
+	if showPaths {
+		status += "  [paths]"
+	}
+	if isPaused() {
+		status += "  [paused]"
+	}
+	sb.WriteString("\r\n" + fit(status, width) + "\r\n")
+	fmt.Print(sb.String())
+}
+
+// Columns the hop table can be sorted by
+const (
+	sortTTL     = "ttl"
+	sortLoss    = "loss"
+	sortLatency = "latency"
+)
+
+// sortModes is the order the s key cycles through
+var sortModes = []string{sortTTL, sortLoss, sortLatency}
+
+// sortHops orders rows by the given column, worst first; ties and the ttl mode keep TTL order
+func sortHops(rows []hop, by string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch by {
+		case sortLoss:
+			return rows[i].Loss > rows[j].Loss
+		case sortLatency:
+			return rows[i].Avg > rows[j].Avg
+		}
+		return rows[i].TTL < rows[j].TTL
+	})
+}
+
+// validSort reports whether by is a known sort mode
+func validSort(by string) bool {
+	for _, mode := range sortModes {
+		if mode == by {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSort returns the sort mode following the current one
+func nextSort(by string) string {
+	for i, mode := range sortModes {
+		if mode == by {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortTTL
 }
 
 func boldText(text string) string {
@@ -0,0 +1,44 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import "testing"
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		width, host, cols int
+	}{
+		{0, fullHostWidth, len(columns)},  // not a terminal
+		{120, 120 - 1 - ttlWidth - 49, 7}, // the host column takes up the rest
+		{60, 60 - 1 - ttlWidth - 34, 5},   // Worst and StDev don't fit
+		{20, minHostWidth, 1},             // only Loss% is left
+	}
+	for _, tt := range tests {
+		host, cols := layout(tt.width)
+		if host != tt.host || len(cols) != tt.cols {
+			t.Errorf("layout(%d) = %d, %d columns; want %d, %d columns", tt.width, host, len(cols), tt.host, tt.cols)
+		}
+		if tt.width > ttlWidth+minHostWidth+columnsWidth(cols) && ttlWidth+host+columnsWidth(cols) >= tt.width {
+			t.Errorf("layout(%d) is %d wide; want it to fit", tt.width, ttlWidth+host+columnsWidth(cols))
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	if got := fit("router.example.net", 10); got != "router...." {
+		t.Errorf("fit() = %q; want %q", got, "router....")
+	}
+	if got := fit("10.0.0.1", 10); got != "10.0.0.1" {
+		t.Errorf("fit() = %q; want it unchanged", got)
+	}
+}
@@ -13,11 +13,15 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
 	"github.com/spf13/cobra"
 )
 
@@ -27,39 +31,127 @@ type hop struct {
 	Addr                        string
 	Sent, Received, Loss        int
 	Sum, Last, Avg, Best, Worst int
+	StDev                       int            // standard deviation of the RTTs in ms, the hop's jitter
+	Addrs                       map[string]int // replies per source address, more than one means ECMP
+	ewma                        float64        // smoothed RTT in ms behind Avg with --avg ewma
+	mean, m2                    float64        // running mean of the RTTs in ms and sum of squared deviations from it, behind StDev
+	Host                        string         // host name of Addr with --resolve, once looked up
 }
 
+// Averages shown in the Avg column
+const (
+	avgMean = "mean"
+	avgEWMA = "ewma"
+)
+
+// ewmaAlpha weights the latest RTT in the --avg ewma average, as TCP's smoothed RTT does (RFC 6298)
+const ewmaAlpha = 0.125
+
 func (h *hop) dataset(pong *icmpkg.Proto) {
 	h.TTL = pong.TTL
 	h.Sent++
 	if h.Addr == "" && pong.Ip4 != "" {
 		h.Addr = pong.Ip4
 	}
-	if pong.Rtt > 0 {
+	if h.Host == "" && pong.Host != "" && pong.Ip4 == h.Addr {
+		h.Host = pong.Host
+	}
+	if !pong.IsTimeout() {
+		if h.Addrs == nil {
+			h.Addrs = make(map[string]int)
+		}
+		h.Addrs[pong.Ip4]++
 		h.Received++
 		h.Last = int(pong.Rtt.Milliseconds())
 		h.Sum += h.Last
-		h.Best = max(min(h.Best, h.Last), h.Last)
-		h.Worst = min(max(h.Worst, h.Last), h.Last)
-		h.Avg = (h.Avg + h.Last) / 2
+		if h.Received == 1 {
+			h.Best, h.Worst = h.Last, h.Last // Seed both from the first sample
+		} else {
+			h.Best, h.Worst = min(h.Best, h.Last), max(h.Worst, h.Last)
+		}
+		// Welford's online variance, so no samples need to be kept
+		rtt := float64(pong.Rtt) / float64(time.Millisecond)
+		delta := rtt - h.mean
+		h.mean += delta / float64(h.Received)
+		h.m2 += delta * (rtt - h.mean)
+		h.StDev = int(math.Round(math.Sqrt(h.m2 / float64(h.Received))))
+		if avgMode == avgEWMA {
+			if h.Received == 1 {
+				h.ewma = float64(h.Last) // Start from the first sample
+			} else {
+				h.ewma += ewmaAlpha * (float64(h.Last) - h.ewma)
+			}
+			h.Avg = int(math.Round(h.ewma))
+		} else {
+			h.Avg = h.Sum / h.Received
+		}
 	}
-	h.Loss = h.Received * 100 / h.Sent
+	h.Loss = (h.Sent - h.Received) * 100 / h.Sent
 	return
 }
 
-var hops [64]hop
+// paths returns the addresses that answered at this hop, most replies first
+func (h *hop) paths() []string {
+	addrs := make([]string, 0, len(h.Addrs))
+	for addr := range h.Addrs {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if h.Addrs[addrs[i]] != h.Addrs[addrs[j]] {
+			return h.Addrs[addrs[i]] > h.Addrs[addrs[j]]
+		}
+		return addrs[i] < addrs[j]
+	})
+	return addrs
+}
+
+var (
+	hops   [64]hop
+	hopsMu sync.Mutex
+)
+
+// resetHops zeroes the counters of every hop
+func resetHops() {
+	hopsMu.Lock()
+	defer hopsMu.Unlock()
+	hops = [64]hop{}
+}
+
+var logFile *cli.RotatingFile
 
 func start() {
+	var err error
+	if logFile, err = cli.OpenLog(logPath, logMaxSize, logMaxBackups); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
 	tr := icmpkg.TracerouteDuration(target, maxTTL, count, interval, readTimeout)
+	tr.Interleave(interleave)
+	tr.ResolveNames(resolve)
 	tr.PongHandler(pongHandler)
 
 	prints(tr.Ip4())
+	printPackets() // Show the column titles before the first pong
+
+	restore := rawTerminal()
+	defer restore()
+	go readKeys(tr)
 
 	tr.Run()
 }
 
 func pongHandler(pong *icmpkg.Proto) {
+	cli.LogJSON(logFile, target, pong)
+	if pong.Dup || pong.OutOfOrder {
+		return // The probe was counted with its first reply or its timeout
+	}
+	hopsMu.Lock()
 	(&hops[pong.TTL]).dataset(pong)
+	hopsMu.Unlock()
+	printPackets()
 }
 
 // rootCmd represents the gomtr root command
@@ -82,6 +174,14 @@ maximum TTL, packets per hop, interval, read timeout, and debug/trace logging.`,
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if !validSort(sortBy) {
+			fmt.Printf("invalid --sort %q: must be one of %v\n", sortBy, sortModes)
+			return
+		}
+		if avgMode != avgMean && avgMode != avgEWMA {
+			fmt.Printf("invalid --avg %q: must be %s or %s\n", avgMode, avgMean, avgEWMA)
+			return
+		}
 		target = args[0]
 		start()
 	},
@@ -89,13 +189,20 @@ maximum TTL, packets per hop, interval, read timeout, and debug/trace logging.`,
 
 // Command-line flags
 var (
-	target      string
-	maxTTL      int           // Maximum TTL (hops)
-	count       int           // Number of ICMP packets per hop
-	interval    time.Duration // Interval between packets
-	readTimeout time.Duration // Read timeout duration
-	debug       bool          // Enable debug logging
-	trace       bool          // Enable trace logging
+	target        string
+	maxTTL        int           // Maximum TTL (hops)
+	count         int           // Number of ICMP packets per hop
+	interval      time.Duration // Interval between packets
+	readTimeout   time.Duration // Read timeout duration
+	interleave    bool          // Spread probes to different hops over time
+	sortBy        string        // Column the hop table is sorted by
+	avgMode       string        // Average shown in the Avg column, mean or ewma
+	resolve       bool          // Look up the host names of hops
+	debug         bool          // Enable debug logging
+	trace         bool          // Enable trace logging
+	logPath       string        // File to log pongs to as JSON lines
+	logMaxSize    int           // Log file size in MB before rotation
+	logMaxBackups int           // Number of rotated log files to keep
 )
 
 func init() {
@@ -104,8 +211,15 @@ func init() {
 	rootCmd.Flags().IntVarP(&count, "count", "c", 10, "Number of ICMP packets per hop")
 	rootCmd.Flags().DurationVarP(&interval, "interval", "i", 100*time.Millisecond, "Interval between packets")
 	rootCmd.Flags().DurationVarP(&readTimeout, "read-timeout", "r", 500*time.Millisecond, "Read timeout duration")
+	rootCmd.Flags().BoolVar(&interleave, "interleave", false, "Spread probes to different hops over time to avoid ICMP rate limits")
+	rootCmd.Flags().StringVar(&sortBy, "sort", sortTTL, "Sort hops by ttl, loss or latency, worst first (cycle with the s key)")
+	rootCmd.Flags().StringVar(&avgMode, "avg", avgMean, "Average shown in the Avg column: mean of all replies, or ewma to smooth towards recent RTTs")
+	rootCmd.Flags().BoolVar(&resolve, "resolve", false, "Look up the host names of hops and show them as host (ip) once known")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug logging")
 	rootCmd.Flags().BoolVar(&trace, "trace", false, "Enable trace logging")
+	rootCmd.Flags().StringVar(&logPath, "log-file", "", "Also log every pong as a JSON line to this file")
+	rootCmd.Flags().IntVar(&logMaxSize, "log-max-size", 10, "Log file size in MB before it is rotated")
+	rootCmd.Flags().IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
 }
 
 // Execute runs the root command
@@ -0,0 +1,37 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+func TestHopDatasetBestWorst(t *testing.T) {
+	var h hop
+	h.dataset(&icmpkg.Proto{TTL: 1, Ip4: "10.0.0.1", Rtt: 12 * time.Millisecond})
+	if h.Best != 12 || h.Worst != 12 {
+		t.Fatalf("first packet: Best = %d, Worst = %d; want 12, 12", h.Best, h.Worst)
+	}
+	for _, ms := range []int{20, 5, 9} {
+		h.dataset(&icmpkg.Proto{TTL: 1, Ip4: "10.0.0.1", Rtt: time.Duration(ms) * time.Millisecond})
+	}
+	if h.Best != 5 || h.Worst != 20 || h.Last != 9 {
+		t.Errorf("Best = %d, Worst = %d, Last = %d; want 5, 20, 9", h.Best, h.Worst, h.Last)
+	}
+	if h.Avg != 11 || h.StDev != 6 {
+		t.Errorf("Avg = %d, StDev = %d; want 11, 6", h.Avg, h.StDev)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+// timeoutRun collects consecutive timeouts so --coalesce can print them as a single line
+type timeoutRun struct {
+	first *icmpkg.Proto // First timeout of the run
+	last  int           // Sequence number of the latest timeout
+	n     int           // Number of timeouts in the run
+}
+
+// add records a timeout
+func (r *timeoutRun) add(pong *icmpkg.Proto) {
+	if r.n == 0 {
+		r.first = pong
+	}
+	r.last = pong.Seq
+	r.n++
+}
+
+// flush prints the collected timeouts, if any, and starts a new run
+func (r *timeoutRun) flush() {
+	switch {
+	case r.n == 1:
+		fmt.Println(r.first.PingLine())
+	case r.n > 1:
+		fmt.Printf("%s timeouts for icmp_id %d icmp_seq %d-%d\n", numFmt.Int(r.n), r.first.ID, r.first.Seq, r.last)
+	}
+	r.n = 0
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
+)
+
+// compareColumn is the width of a target column in compare mode.
+const compareColumn = 28
+
+// runCompare pings two targets side by side, printing one row per sequence number once both replied.
+func runCompare(a, b string) {
+	multi := icmpkg.MultiPingDuration([]string{a, b}, count, writeTimeout, readTimeout)
+	for _, ping := range multi.Pings() {
+		ping.Deadline(deadline)
+		ping.Tag(tag)
+		if err := cli.BindInterface(ping, iface); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := ping.TOS(tos); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	targets := []string{a, b}
+	fmt.Printf("%-6s %-*s %-*s\n", "seq", compareColumn, compareHeader(a, multi.Pings()[0].Ip4()), compareColumn, compareHeader(b, multi.Pings()[1].Ip4()))
+
+	mu := &sync.Mutex{}
+	rows := make(map[int][2]*icmpkg.Proto) // Pongs waiting for the other target, keyed by sequence number.
+	multi.PongHandler(func(target string, pong *icmpkg.Proto) {
+		mu.Lock()
+		defer mu.Unlock()
+		pong.Rtt = cli.FloorRTT(pong.Rtt, rttFloor) // Show sub-threshold RTTs as 0
+		row := rows[pong.Seq]
+		if target == targets[0] {
+			row[0] = pong
+		} else {
+			row[1] = pong
+		}
+		if row[0] == nil || row[1] == nil {
+			rows[pong.Seq] = row
+			return
+		}
+		delete(rows, pong.Seq)
+		fmt.Printf("%-6d %-*s %-*s\n", pong.Seq, compareColumn, compareCell(row[0]), compareColumn, compareCell(row[1]))
+	})
+	release := cli.StopOnSignal(multi.Stop) // Ctrl-C ends Run, which then prints the summary once
+	multi.Run()
+	release()
+
+	fmt.Printf("\n--- %s vs %s ping statistics ---\n", a, b)
+	for _, st := range multi.Stats() {
+		fmt.Printf("%s: %s packets transmitted, %s received, %s%% packet loss\n", st.Target, numFmt.Int(st.Transmitted), numFmt.Int(st.Received), numFmt.Float(st.Loss, 1))
+	}
+}
+
+// compareHeader formats a column header for a target.
+func compareHeader(target, ip4 string) string {
+	if ip4 == "" || ip4 == target {
+		return target
+	}
+	return fmt.Sprintf("%s (%s)", target, ip4)
+}
+
+// compareCell formats a pong as a column cell.
+func compareCell(pong *icmpkg.Proto) string {
+	if pong.IsTimeout() {
+		return "timeout"
+	}
+	return numFmt.Int(int(pong.Rtt.Milliseconds())) + " ms"
+}
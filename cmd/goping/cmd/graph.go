@@ -0,0 +1,108 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// graphSize is the number of recent RTTs kept for the sparkline
+const graphSize = 256
+
+// sparks are the sparkline levels, from lowest to highest RTT
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// rttRing keeps the most recent RTTs in milliseconds; timeouts are stored as -1
+type rttRing struct {
+	rtts []float64
+	next int
+	full bool
+}
+
+// newRTTRing creates a ring holding up to size RTTs
+func newRTTRing(size int) *rttRing {
+	return &rttRing{rtts: make([]float64, size)}
+}
+
+// add records an RTT, overwriting the oldest one once the ring is full
+func (r *rttRing) add(rtt float64) {
+	r.rtts[r.next] = rtt
+	r.next = (r.next + 1) % len(r.rtts)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// values returns the recorded RTTs from oldest to newest
+func (r *rttRing) values() []float64 {
+	if !r.full {
+		return r.rtts[:r.next]
+	}
+	return append(append([]float64(nil), r.rtts[r.next:]...), r.rtts[:r.next]...)
+}
+
+// sparkline renders the last width RTTs, scaled between their min and max; timeouts render as '·'
+func (r *rttRing) sparkline(width int) string {
+	values := r.values()
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	min, max := -1.0, -1.0
+	for _, v := range values {
+		if v < 0 {
+			continue
+		}
+		if min < 0 || v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		switch {
+		case v < 0:
+			sb.WriteRune('·')
+		case max == min:
+			sb.WriteRune(sparks[0])
+		default:
+			sb.WriteRune(sparks[int((v-min)/(max-min)*float64(len(sparks)-1)+0.5)])
+		}
+	}
+	return sb.String()
+}
+
+// graphEnabled reports whether the live graph can be drawn, i.e. stdout is a terminal
+func graphEnabled() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// drawGraph redraws the sparkline line in place with the latest RTT
+func drawGraph(ring *rttRing, last float64) {
+	status := "timeout"
+	if last >= 0 {
+		status = numFmt.Float(last, 1) + " ms"
+	}
+	termWidth, _, _ := term.GetSize(int(os.Stdout.Fd()))
+	width := termWidth - len(status) - 3
+	if width < 10 {
+		width = 10
+	}
+	fmt.Printf("\r\033[K%s  %s", ring.sparkline(width), status)
+}
@@ -14,18 +14,33 @@ package cmd
 import (
 	"fmt"
 	"time"
+
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
 )
 
-// protoOutput adapts icmpkg.Proto for JSON/XML serialization
+// protoOutput adapts icmpkg.Proto for JSON/XML/CSV serialization
 type protoOutput struct {
+	TTL int           `json:"ttl" xml:"TTL"` // TTL set on the probe, 0 for the system default
 	ID  int           `json:"id" xml:"ID"`
 	Seq int           `json:"seq" xml:"Seq"`
 	Ip4 string        `json:"ip4" xml:"Ip4"`
 	Rtt time.Duration `json:"rtt" xml:"Rtt"`
+	Tag string        `json:"tag,omitempty" xml:"Tag,omitempty"`
+	// Payload tells with --payload-file whether a reply echoed the payload "intact" or "altered"
+	Payload string `json:"payload,omitempty" xml:"Payload,omitempty"`
+	// Dup marks a reply duplicating one already received, ping's "(DUP!)"
+	Dup bool `json:"dup,omitempty" xml:"Dup,omitempty"`
+	// OutOfOrder marks a reply to a probe that was already reported as timed out
+	OutOfOrder bool `json:"out_of_order,omitempty" xml:"OutOfOrder,omitempty"`
+}
+
+// csvRecord returns the --csv row of the probe, whose RTT is left empty if it timed out
+func (p *protoOutput) csvRecord(timeout bool) []string {
+	return cli.CSVRecord(p.TTL, p.ID, p.Seq, p.Ip4, p.Rtt, timeout)
 }
 
 // String returns a string representation of the Proto instance for logging or debugging.
 func (p *protoOutput) String() string {
 	// Format the Proto fields into a human-readable string.
-	return fmt.Sprintf("ID: %d, Seq: %d, Ip4: %v, Rtt: %v", p.ID, p.Seq, p.Ip4, p.Rtt)
+	return fmt.Sprintf("TTL: %d, ID: %d, Seq: %d, Ip4: %v, Rtt: %v", p.TTL, p.ID, p.Seq, p.Ip4, p.Rtt)
 }
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
 	"github.com/spf13/cobra"
 )
 
@@ -30,8 +31,14 @@ var rootCmd = &cobra.Command{
 	Short: "goping is a command-line tool for ICMP ping",
 	Long: `goping is a command-line tool based on the icmpkg package for performing ICMP ping operations.
 It supports configuration of target address, packet count, write timeout, read timeout, packet ID, sequence number,
-output format (text, json, xml), and signal handling for graceful shutdown.`,
-	Args: cobra.ExactArgs(1), // Requires exactly one argument (target address)
+output format (text, json, xml), and signal handling for graceful shutdown.
+With --compare it takes two targets and shows their RTT and loss side by side.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if compare {
+			return cobra.ExactArgs(2)(cmd, args) // Requires two target addresses in compare mode
+		}
+		return cobra.ExactArgs(1)(cmd, args) // Requires exactly one argument (target address)
+	},
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		// Set debug and trace environment variables
 		if debug {
@@ -44,52 +51,160 @@ output format (text, json, xml), and signal handling for graceful shutdown.`,
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		if numFmt, err = cli.ParseLocale(locale); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if compare {
+			runCompare(args[0], args[1])
+			return
+		}
 		target := args[0]
-		ping := icmpkg.PingDuration(target, count, writeTimeout, readTimeout)
-		var stats pingStats
-		sys := !textOutput && !jsonOutput && !xmlOutput
+		addr, ok := selectFamily(target)
+		if !ok {
+			return
+		}
+		logFile, err := cli.OpenLog(logPath, logMaxSize, logMaxBackups)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if logFile != nil {
+			defer logFile.Close()
+		}
+		ping := icmpkg.PingDuration(addr, count, writeTimeout, readTimeout)
+		ping.Deadline(deadline)
+		ping.Tag(tag)
+		ping.ReplyRate(replyRate)
+		ping.LateGrace(lateGrace)
+		ping.KernelTimestamps(kernelTS)
+		if err := cli.BindInterface(ping, iface); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := ping.TOS(tos); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if payloadFile != "" {
+			if err := ping.PayloadFile(payloadFile); err != nil {
+				fmt.Println(err)
+				return
+			}
+			ping.VerifyPayload(0) // Flag replies echoing anything but the file's bytes as altered
+		}
+		sys := !textOutput && !jsonOutput && !xmlOutput && !influx && !csvOutput
+		var csv *cli.CSV
+		if csvOutput {
+			csv = cli.NewCSV(os.Stdout) // Header once, then a row per pong
+		}
 		if sys {
 			// Print header similar to system ping
-			fmt.Printf("PING %s (%s) 56 bytes of data.\n", target, ping.Ip4())
+			ip := ping.Ip4()
+			if anonymize {
+				ip = cli.Anonymize(ip)
+			}
+			if payloadFile != "" {
+				fmt.Printf("PING %s (%s) %d bytes of data from %s.\n", target, ip, ping.PayloadLen(), payloadFile)
+			} else {
+				fmt.Printf("PING %s (%s) %d bytes of data.\n", target, ip, ping.PayloadLen())
+			}
 		}
 
 		// Set PongHandler based on output format
+		var ring *rttRing
+		var timeouts timeoutRun // Consecutive timeouts held back by --coalesce
+		if sys && graph && graphEnabled() {
+			ring = newRTTRing(graphSize) // Live sparkline instead of per-reply lines
+		}
 		ping.PongHandler(func(pong *icmpkg.Proto) {
+			if anonymize {
+				cli.AnonymizeProto(pong) // Mask the last octet of the replying address
+			}
+			pong.Rtt = cli.FloorRTT(pong.Rtt, rttFloor) // Show sub-threshold RTTs as 0
 			outputProto := protoOutput{
+				TTL: pong.TTL,
 				ID:  pong.ID,
 				Seq: pong.Seq,
 				Ip4: pong.Ip4,
 				Rtt: pong.Rtt,
+				Tag: pong.Tag,
+				Dup: pong.Dup,
+
+				OutOfOrder: pong.OutOfOrder,
 			}
-			if jsonOutput {
+			if payloadFile != "" && !pong.IsTimeout() && !pong.IsError() {
+				outputProto.Payload = "intact"
+				if pong.Corrupt {
+					outputProto.Payload = "altered"
+				}
+			}
+			cli.LogJSON(logFile, target, outputProto)
+			if influx {
+				fmt.Println(cli.InfluxProbe(target, pong))
+			} else if jsonOutput {
 				data, _ := json.Marshal(outputProto)
 				fmt.Println(string(data))
 			} else if xmlOutput {
 				data, _ := xml.Marshal(outputProto)
 				fmt.Printf("%s\n", data)
+			} else if csvOutput {
+				csv.Write(outputProto.csvRecord(pong.IsTimeout()))
 			} else if textOutput {
 				fmt.Println(outputProto.String())
 			} else {
 				// System ping-style output
-				stats.transmitted++
-				if pong.Rtt == 0 {
-					fmt.Printf("Request timeout for icmp_id %d icmp_seq %d\n", pong.ID, pong.Seq)
+				rttMs := -1.0
+				if !pong.IsTimeout() {
+					rttMs = float64(pong.Rtt) / float64(time.Millisecond)
+				}
+				if ring != nil {
+					ring.add(rttMs)
+					drawGraph(ring, rttMs)
+				} else if pong.IsTimeout() && coalesce {
+					timeouts.add(pong)
 				} else {
-					stats.received++
-					fmt.Printf("64 bytes from %s: icmp_id=%d icmp_seq=%d time=%d ms\n", pong.Ip4, pong.ID, pong.Seq, pong.Rtt.Milliseconds())
+					timeouts.flush() // A reply ends the run of timeouts
+					if outputProto.Payload != "" {
+						fmt.Printf("%s (payload %s)\n", pong.PingLine(), outputProto.Payload)
+					} else {
+						fmt.Println(pong.PingLine())
+					}
 				}
-				rttMs := float64(pong.Rtt) / float64(time.Millisecond)
-				stats.rttS = append(stats.rttS, rttMs)
 			}
 		})
+		release := cli.StopOnSignal(ping.Stop) // Ctrl-C ends Run, which then prints the summary once
 		ping.Run()
+		release()
+		timeouts.flush()
+		if influx {
+			fmt.Println(cli.InfluxSummary(target, tag, ping.Stats(), time.Now()))
+		}
+		if ring != nil {
+			fmt.Println() // End the graph line
+		}
 		if sys {
-			loss := float64(stats.transmitted-stats.received) / float64(stats.transmitted) * 100
+			st := ping.Stats()
 			fmt.Printf("\n--- %s ping statistics ---\n", target)
-			fmt.Printf("%d packets transmitted, %d received, %.1f%% packet loss\n", stats.transmitted, stats.received, loss)
-			if len(stats.rttS) > 0 {
-				min, avg, max, mdev := calculateRTTStats(stats.rttS)
-				fmt.Printf("rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n", min, avg, max, mdev)
+			late := ""
+			if st.Late > 0 {
+				late = fmt.Sprintf(" (%s late)", numFmt.Int(st.Late)) // Replies credited within --late-grace
+			}
+			dups := ""
+			if st.Duplicates > 0 {
+				dups = fmt.Sprintf(", +%s duplicates", numFmt.Int(st.Duplicates)) // Like ping, apart from the received count
+			}
+			if st.OutOfOrder > 0 {
+				dups += fmt.Sprintf(", +%s out of order", numFmt.Int(st.OutOfOrder)) // Replies to probes counted as lost
+			}
+			fmt.Printf("%s packets transmitted, %s received%s%s, %s%% packet loss\n", numFmt.Int(st.Transmitted), numFmt.Int(st.Received), late, dups, numFmt.Float(st.Loss, 1))
+			fmt.Printf("%s bytes sent, %s bytes received\n", numFmt.Int(int(st.BytesSent)), numFmt.Int(int(st.BytesReceived)))
+			if st.Received > 0 {
+				ms := func(d time.Duration) string {
+					return numFmt.Float(float64(cli.FloorRTT(d, rttFloor))/float64(time.Millisecond), 3)
+				}
+				fmt.Printf("rtt min/avg/max/mdev = %s/%s/%s/%s ms\n", ms(st.Min), ms(st.Avg), ms(st.Max), ms(st.StdDev))
 			}
 		}
 	},
@@ -97,28 +212,72 @@ output format (text, json, xml), and signal handling for graceful shutdown.`,
 
 // Command-line flags
 var (
-	count        int           // Number of ICMP packets to send
-	writeTimeout time.Duration // Write timeout duration
-	readTimeout  time.Duration // Read timeout duration
-	textOutput   bool          // Enable Text output
-	jsonOutput   bool          // Enable JSON output
-	xmlOutput    bool          // Enable XML output
-	debug        bool          // Enable debug logging
-	trace        bool          // Enable trace logging
+	count         int           // Number of ICMP packets to send
+	writeTimeout  time.Duration // Write timeout duration
+	readTimeout   time.Duration // Read timeout duration
+	deadline      time.Duration // Stop after this duration regardless of count
+	replyRate     float64       // Target replies per second, 0 for fixed pacing
+	lateGrace     time.Duration // Credit replies arriving this long after the read timeout as late
+	kernelTS      bool          // Time replies with kernel receive timestamps
+	compare       bool          // Compare two targets side by side
+	textOutput    bool          // Enable Text output
+	jsonOutput    bool          // Enable JSON output
+	xmlOutput     bool          // Enable XML output
+	csvOutput     bool          // Enable CSV output
+	influx        bool          // Enable InfluxDB line protocol output
+	anonymize     bool          // Mask the last octet of addresses
+	graph         bool          // Show a live RTT sparkline
+	coalesce      bool          // Print consecutive timeouts as one line
+	rttFloor      time.Duration // RTTs below this are shown as 0
+	locale        string        // Locale for number formatting
+	tag           string        // Run ID for correlating logs and output
+	iface         string        // Interface name, index or local address to send probes from
+	tos           int           // Type-of-Service byte to mark probes with
+	prefer        string        // Address family policy for dual-stack targets
+	payloadFile   string        // File whose bytes are sent verbatim as the Echo payload
+	debug         bool          // Enable debug logging
+	trace         bool          // Enable trace logging
+	logPath       string        // File to log pongs to as JSON lines
+	logMaxSize    int           // Log file size in MB before rotation
+	logMaxBackups int           // Number of rotated log files to keep
 )
 
 func init() {
 	// Add flags
-	rootCmd.Flags().IntVarP(&count, "count", "c", 3, "Number of ICMP packets to send")
+	rootCmd.Flags().IntVarP(&count, "count", "c", 3, "Number of ICMP packets to send, 0 to ping until interrupted")
 	rootCmd.Flags().DurationVarP(&writeTimeout, "write-timeout", "w", 500*time.Millisecond, "Write timeout duration")
 	rootCmd.Flags().DurationVarP(&readTimeout, "read-timeout", "r", 500*time.Millisecond, "Read timeout duration")
+	rootCmd.Flags().DurationVarP(&deadline, "deadline", "W", 0, "Stop after this duration regardless of count (like ping -w)")
+	rootCmd.Flags().Float64Var(&replyRate, "reply-rate", 0, "Adapt the send rate to receive about this many replies per second, catching up after losses (at most one per RTT)")
+	rootCmd.Flags().DurationVar(&lateGrace, "late-grace", 0, "Credit replies arriving up to this long after the read timeout as received but late instead of lost")
+	rootCmd.Flags().BoolVar(&kernelTS, "kernel-timestamps", false, "Time replies on arrival with kernel receive timestamps, keeping scheduling jitter out of the RTTs (Linux only)")
+	rootCmd.Flags().BoolVar(&compare, "compare", false, "Ping two targets and compare their RTT/loss side by side")
 	rootCmd.Flags().BoolVarP(&textOutput, "text", "t", false, "Enable Text output")
 	rootCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Enable JSON output")
 	rootCmd.Flags().BoolVarP(&xmlOutput, "xml", "x", false, "Enable XML output")
+	rootCmd.Flags().BoolVar(&csvOutput, "csv", false, "Enable CSV output, a ttl,id,seq,ip4,rtt_ms header and one row per pong")
+	rootCmd.Flags().BoolVar(&influx, "influx", false, "Enable InfluxDB line protocol output, one line per probe and a summary line, e.g. for telegraf")
+	rootCmd.Flags().BoolVar(&graph, "graph", false, "Show a live RTT sparkline instead of per-reply lines (terminal only)")
+	rootCmd.Flags().BoolVar(&coalesce, "coalesce", false, "Print consecutive timeouts as a single \"N timeouts\" line once a reply arrives or the run ends")
+	rootCmd.Flags().DurationVar(&rttFloor, "rtt-floor", 0, "Show RTTs below this duration as 0 (local), e.g. 1ms to hide loopback and LAN noise")
+	rootCmd.Flags().BoolVar(&anonymize, "anonymize", false, "Mask the last octet of addresses (e.g. 10.0.0.x), or the interface identifier of IPv6 ones (2001:db8:1:2::x), for sharing output")
+	rootCmd.Flags().StringVar(&locale, "locale", "", "Format numbers for a locale such as de_DE or fr, or auto to read LC_ALL/LC_NUMERIC/LANG")
+	rootCmd.Flags().StringVarP(&iface, "interface", "I", "", "Send probes from this interface, given by name (eth0), index (2) or local address (192.0.2.1)")
+	rootCmd.Flags().StringVar(&prefer, "prefer", "", "Measure one family of a dual-stack target, picked after pinging both: v4, v6, faster or v6-within=DURATION (e.g. v6-within=20ms)")
+	rootCmd.Flags().IntVarP(&tos, "tos", "Q", 0, "Mark probes with this Type-of-Service byte (0-255, DSCP << 2, e.g. 184 for EF), or IPv6 traffic class")
+	rootCmd.Flags().StringVar(&payloadFile, "payload-file", "", "Send the bytes of this file verbatim as the Echo payload, e.g. a crafted payload for IDS testing, and report whether replies echo it intact or altered")
+	rootCmd.Flags().StringVar(&tag, "tag", "", "Run ID to prefix debug logs with and include in JSON/XML output")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug logging")
 	rootCmd.Flags().BoolVar(&trace, "trace", false, "Enable trace logging")
+	rootCmd.Flags().StringVar(&logPath, "log-file", "", "Also log every pong as a JSON line to this file")
+	rootCmd.Flags().IntVar(&logMaxSize, "log-max-size", 10, "Log file size in MB before it is rotated")
+	rootCmd.Flags().IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
+	rootCmd.MarkFlagsMutuallyExclusive("text", "json", "xml", "csv", "influx")
 }
 
+// numFmt formats the RTTs, counts and percentages of the summary, set from --locale
+var numFmt = cli.DefaultNumberFormat
+
 // Execute runs the root command
 func Execute() {
 	defer func() {
@@ -130,3 +289,25 @@ func Execute() {
 		fmt.Println(err)
 	}
 }
+
+// selectFamily returns the address to ping for target, picked by --prefer if set, printing the decision
+// unless the output is machine-readable. It reports false if the target can't be measured
+func selectFamily(target string) (string, bool) {
+	policy, err := cli.ParseFamilyPolicy(prefer)
+	if err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	if policy == nil {
+		return target, true // Leave the family to the resolver
+	}
+	decision, err := icmpkg.SelectFamily(target, policy)
+	if err != nil {
+		fmt.Println(err)
+		return "", false
+	}
+	if !jsonOutput && !xmlOutput && !influx && !csvOutput {
+		fmt.Println(decision)
+	}
+	return decision.Address, true
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
+)
+
+// runAll traces every address the host resolves to and prints each path, pointing out addresses that
+// share a path with an earlier one
+func runAll(host string) {
+	m := icmpkg.TracerouteAllDuration(host, maxTTL, count, writeTimeout, readTimeout)
+	m.PongHandler(func(_ string, pong *icmpkg.Proto) { maskProto(pong) }) // The reports share the masked Protos
+	for _, tr := range m.Pings() {
+		tr.Interleave(interleave)
+		tr.GiveUpAfter(giveUp)
+		tr.DestinationCount(destCount)
+		tr.Tag(tag)
+		if err := cli.BindInterface(tr, iface); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := tr.TOS(tos); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	reports := m.RunReports()
+	if len(reports) == 0 {
+		fmt.Printf("cannot resolve %s\n", host)
+		return
+	}
+	if jsonOutput {
+		data, _ := json.Marshal(reports)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("traceroute to %s, %d addresses\n", host, len(reports))
+	paths := make([][]string, len(reports))
+	distinct := 0
+	for i, rep := range reports {
+		paths[i] = rep.Path()
+		same := -1
+		for j := 0; j < i && same < 0; j++ {
+			if reflect.DeepEqual(paths[i], paths[j]) {
+				same = j
+			}
+		}
+		if same >= 0 {
+			fmt.Printf("\n%s: same path as %s\n", rep.Ip4, reports[same].Ip4)
+			continue
+		}
+		distinct++
+		fmt.Printf("\n%s:\n", rep.Ip4)
+		for h, addr := range paths[i] {
+			fmt.Printf("%3d  %s\n", rep.Hops[h].TTL, addr)
+		}
+	}
+	fmt.Printf("\n%d distinct paths to %d addresses\n", distinct, len(reports))
+}
+
+// allConflict returns the name of a flag set alongside --all that it would ignore, or "" if there is none
+func allConflict() string {
+	conflicts := []struct {
+		set  bool
+		name string
+	}{
+		{csvOutput, "csv"}, {xmlOutput, "xml"}, {influx, "influx"}, {dot, "dot"}, {pathSummary, "path"},
+		{perHop, "per-hop"}, {logPath != "", "log-file"}, {resolve, "resolve"},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// maskProto applies --mask-private and --anonymize to the hop address of pong in place
+func maskProto(pong *icmpkg.Proto) {
+	if maskPrivate && pong.Private {
+		pong.Addr, pong.Ip4 = nil, "private" // Hide internal hops
+	}
+	if anonymize {
+		cli.AnonymizeProto(pong) // Mask the last octet of hop addresses
+	}
+}
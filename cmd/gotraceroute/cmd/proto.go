@@ -14,15 +14,26 @@ package cmd
 import (
 	"fmt"
 	"time"
+
+	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
 )
 
-// protoOutput adapts icmpkg.Proto for JSON/XML serialization
+// protoOutput adapts icmpkg.Proto for JSON/XML/CSV serialization
 type protoOutput struct {
-	TTL int           `json:"ttl" xml:"TTL"`
-	ID  int           `json:"id" xml:"ID"`
-	Seq int           `json:"seq" xml:"Seq"`
-	Ip4 string        `json:"ip4" xml:"Ip4"`
-	Rtt time.Duration `json:"rtt" xml:"Rtt"`
+	TTL  int           `json:"ttl" xml:"TTL"`
+	ID   int           `json:"id" xml:"ID"`
+	Seq  int           `json:"seq" xml:"Seq"`
+	Ip4  string        `json:"ip4" xml:"Ip4"`
+	Rtt  time.Duration `json:"rtt" xml:"Rtt"`
+	Tag  string        `json:"tag,omitempty" xml:"Tag,omitempty"`
+	NAT  string        `json:"nat,omitempty" xml:"NAT,omitempty"`   // Source address quoted by a hop behind a NAT
+	Host string        `json:"host,omitempty" xml:"Host,omitempty"` // Host name of Ip4 with --resolve
+}
+
+// csvRecord returns the --csv row of the probe, whose RTT is left empty if it timed out
+func (p *protoOutput) csvRecord(timeout bool) []string {
+	return cli.CSVRecord(p.TTL, p.ID, p.Seq, p.Ip4, p.Rtt, timeout)
 }
 
 // String returns a string representation of the Proto instance for logging or debugging.
@@ -30,3 +41,39 @@ func (p *protoOutput) String() string {
 	// Format the Proto fields into a human-readable string.
 	return fmt.Sprintf("TTL: %d, ID: %d, Seq: %d, Ip4: %v, Rtt: %v", p.TTL, p.ID, p.Seq, p.Ip4, p.Rtt)
 }
+
+// hopOutput aggregates the probes of one hop for --per-hop JSON output
+type hopOutput struct {
+	TTL      int             `json:"ttl"`
+	Addr     string          `json:"addr"`
+	Sent     int             `json:"sent"`
+	Received int             `json:"received"`
+	Loss     float64         `json:"loss"`
+	Rtts     []time.Duration `json:"rtts"`
+	Best     time.Duration   `json:"best"`
+	Avg      time.Duration   `json:"avg"`
+	Worst    time.Duration   `json:"worst"`
+	Tag      string          `json:"tag,omitempty"`
+}
+
+// newHopOutput summarizes a hop of a report, addr being the hop's address from Report.Path
+func newHopOutput(h icmpkg.HopReport, addr string) hopOutput {
+	out := hopOutput{
+		TTL:      h.TTL,
+		Addr:     addr,
+		Sent:     h.Statistics.Transmitted,
+		Received: h.Statistics.Received,
+		Loss:     h.Statistics.Loss,
+		Rtts:     []time.Duration{},
+		Best:     cli.FloorRTT(h.Statistics.Min, rttFloor),
+		Avg:      cli.FloorRTT(h.Statistics.Avg, rttFloor),
+		Worst:    cli.FloorRTT(h.Statistics.Max, rttFloor),
+		Tag:      tag,
+	}
+	for _, pto := range h.Probes {
+		if !pto.IsTimeout() {
+			out.Rtts = append(out.Rtts, cli.FloorRTT(pto.Rtt, rttFloor))
+		}
+	}
+	return out
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/go-the-way/icmpkg"
+	"github.com/go-the-way/icmpkg/cmd/internal/cli"
 	"github.com/spf13/cobra"
 )
 
@@ -45,52 +46,195 @@ sequence number, output format (text, json, xml), and signal handling for gracef
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		target := args[0]
+		if perHop && !jsonOutput {
+			fmt.Println("--per-hop requires --json")
+			return
+		}
+		if dedup && !pathSummary {
+			fmt.Println("--dedup requires --path")
+			return
+		}
+		if all {
+			if flag := allConflict(); flag != "" {
+				fmt.Printf("--all cannot be combined with --%s\n", flag)
+				return
+			}
+			runAll(target)
+			return
+		}
+		logFile, err := cli.OpenLog(logPath, logMaxSize, logMaxBackups)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if logFile != nil {
+			defer logFile.Close()
+		}
 		tr := icmpkg.TracerouteDuration(target, maxTTL, count, writeTimeout, readTimeout)
+		tr.Interleave(interleave)
+		tr.GiveUpAfter(giveUp)
+		tr.DestinationCount(destCount)
+		tr.ResolveNames(resolve)
+		tr.Tag(tag)
+		if err := cli.BindInterface(tr, iface); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := tr.TOS(tos); err != nil {
+			fmt.Println(err)
+			return
+		}
+		var csv *cli.CSV
+		if csvOutput && !perHop && !dot {
+			csv = cli.NewCSV(os.Stdout) // Header once, then a row per pong
+		}
 		// Set PongHandler based on output format
 		tr.PongHandler(func(pong *icmpkg.Proto) {
+			maskProto(pong)
+			pong.Rtt = cli.FloorRTT(pong.Rtt, rttFloor) // Show sub-threshold RTTs as 0
 			outputProto := protoOutput{
-				TTL: pong.TTL,
-				ID:  pong.ID,
-				Seq: pong.Seq,
-				Ip4: pong.Ip4,
-				Rtt: pong.Rtt,
+				TTL:  pong.TTL,
+				ID:   pong.ID,
+				Seq:  pong.Seq,
+				Ip4:  pong.Ip4,
+				Rtt:  pong.Rtt,
+				Tag:  pong.Tag,
+				NAT:  pong.QuotedSrc,
+				Host: pong.Host,
 			}
-			if jsonOutput {
+			cli.LogJSON(logFile, target, outputProto)
+			if perHop || dot {
+				return // Hops are printed once the trace finishes
+			} else if influx {
+				fmt.Println(cli.InfluxProbe(target, pong))
+			} else if jsonOutput {
 				data, _ := json.Marshal(outputProto)
 				fmt.Println(string(data))
 			} else if xmlOutput {
 				data, _ := xml.Marshal(outputProto)
 				fmt.Printf("%s\n", data)
+			} else if csvOutput {
+				csv.Write(outputProto.csvRecord(pong.IsTimeout()))
 			} else {
-				fmt.Println(pong.String())
+				if pong.Host != "" {
+					shown := pong.Clone()
+					shown.Ip4 = cli.HostLabel(pong) // Print "host (ip)" without renaming the hop in the report
+					pong = shown
+				}
+				if pong.NAT {
+					fmt.Printf("%s NAT (quoted src %s)\n", pong, pong.QuotedSrc)
+				} else if mark := pong.ErrorMark(); mark != "" {
+					fmt.Printf("%s %s\n", pong, mark) // Annotate ICMP errors like traceroute(8)
+				} else if pong.Dup {
+					fmt.Printf("%s (DUP!)\n", pong)
+				} else if pong.OutOfOrder {
+					fmt.Printf("%s (out of order)\n", pong)
+				} else {
+					fmt.Println(pong.String())
+				}
 			}
 		})
-		tr.Run()
+		if perHop {
+			rep := tr.RunReport()
+			for i, addr := range rep.Path() {
+				data, _ := json.Marshal(newHopOutput(rep.Hops[i], addr))
+				fmt.Println(string(data))
+			}
+		} else if dot {
+			if err := icmpkg.WriteDOT(os.Stdout, tr.RunReport()); err != nil {
+				fmt.Println(err)
+			}
+			return
+		} else if pathSummary {
+			rep := tr.RunReport()
+			if dedup {
+				fmt.Println(rep.DedupPathSummary()) // Show a router answering consecutive TTLs once
+			} else {
+				fmt.Println(rep.PathSummary()) // The summary also tells whether the target was reached
+			}
+			return
+		} else if summary {
+			printSummary(tr.RunReport()) // Probe lines first, then the rollup
+		} else {
+			tr.Run()
+		}
+		if influx {
+			fmt.Println(cli.InfluxSummary(target, tag, tr.Stats(), time.Now()))
+			return
+		}
+		if tr.Status() == icmpkg.StatusUnreached && !jsonOutput && !xmlOutput && !csvOutput {
+			fmt.Printf("%s not reached within %d hops\n", target, maxTTL)
+		}
 	},
 }
 
 // Command-line flags
 var (
-	maxTTL       int           // Maximum TTL (hops)
-	count        int           // Number of ICMP packets per hop
-	writeTimeout time.Duration // Write timeout duration
-	readTimeout  time.Duration // Read timeout duration
-	jsonOutput   bool          // Enable JSON output
-	xmlOutput    bool          // Enable XML output
-	debug        bool          // Enable debug logging
-	trace        bool          // Enable trace logging
+	maxTTL        int           // Maximum TTL (hops)
+	count         int           // Number of ICMP packets per hop
+	writeTimeout  time.Duration // Write timeout duration
+	readTimeout   time.Duration // Read timeout duration
+	jsonOutput    bool          // Enable JSON output
+	xmlOutput     bool          // Enable XML output
+	csvOutput     bool          // Enable CSV output
+	influx        bool          // Enable InfluxDB line protocol output
+	maskPrivate   bool          // Mask private and bogon hop addresses
+	anonymize     bool          // Mask the last octet of hop addresses
+	interleave    bool          // Spread probes to different hops over time
+	all           bool          // Trace every resolved address of the target
+	giveUp        int           // Stop after this many consecutive unanswered hops
+	destCount     int           // Number of ICMP packets to the destination's hop
+	resolve       bool          // Look up the host names of hops
+	perHop        bool          // Emit one JSON object per hop instead of per probe
+	rttFloor      time.Duration // RTTs below this are shown as 0
+	pathSummary   bool          // Print the path on one line once the trace finishes
+	summary       bool          // Print per-hop loss and min/avg/max once the trace finishes
+	dedup         bool          // Collapse a router answering consecutive TTLs in the path
+	dot           bool          // Print the path as a Graphviz DOT graph once the trace finishes
+	tag           string        // Run ID for correlating logs and output
+	iface         string        // Interface name, index or local address to send probes from
+	tos           int           // Type-of-Service byte to mark probes with
+	debug         bool          // Enable debug logging
+	trace         bool          // Enable trace logging
+	logPath       string        // File to log pongs to as JSON lines
+	logMaxSize    int           // Log file size in MB before rotation
+	logMaxBackups int           // Number of rotated log files to keep
 )
 
 func init() {
 	// Add flags
 	rootCmd.Flags().IntVarP(&maxTTL, "max-ttl", "m", 30, "Maximum TTL (hops)")
 	rootCmd.Flags().IntVarP(&count, "count", "c", 3, "Number of ICMP packets per hop")
+	rootCmd.Flags().IntVar(&destCount, "dest-count", 0, "Number of ICMP packets to the hop the destination answers at, e.g. 10 to sample it more than --count (0 uses --count)")
 	rootCmd.Flags().DurationVarP(&writeTimeout, "write-timeout", "w", 500*time.Millisecond, "Write timeout duration")
 	rootCmd.Flags().DurationVarP(&readTimeout, "read-timeout", "r", 500*time.Millisecond, "Read timeout duration")
 	rootCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Enable JSON output")
 	rootCmd.Flags().BoolVarP(&xmlOutput, "xml", "x", false, "Enable XML output")
+	rootCmd.Flags().BoolVar(&csvOutput, "csv", false, "Enable CSV output, a ttl,id,seq,ip4,rtt_ms header and one row per pong")
+	rootCmd.Flags().BoolVar(&influx, "influx", false, "Enable InfluxDB line protocol output, one line per probe and a summary line, e.g. for telegraf")
+	rootCmd.Flags().DurationVar(&rttFloor, "rtt-floor", 0, "Show RTTs below this duration as 0 (local), e.g. 1ms to hide loopback and LAN noise")
+	rootCmd.Flags().BoolVar(&perHop, "per-hop", false, "With --json, emit one summary object per hop (addr, loss, rtts, best/avg/worst) when the trace finishes")
+	rootCmd.Flags().BoolVar(&pathSummary, "path", false, "Print the discovered path on one line when the trace finishes, e.g. > 10.0.0.1 > 8.8.8.8 (reached in 2 hops)")
+	rootCmd.Flags().BoolVar(&summary, "summary", false, "Print a table of every hop's loss and min/avg/max RTT after the probe lines when the trace finishes")
+	rootCmd.Flags().BoolVar(&dot, "dot", false, "Print the discovered paths, including ECMP branches, as a Graphviz DOT graph when the trace finishes, e.g. | dot -Tpng -o path.png")
+	rootCmd.Flags().BoolVar(&dedup, "dedup", false, "With --path, show a router answering consecutive TTLs once, noting the TTLs, e.g. 10.0.1.1 (ttl 2-3)")
+	rootCmd.Flags().BoolVar(&maskPrivate, "mask-private", false, "Mask the addresses of private and bogon hops")
+	rootCmd.Flags().BoolVar(&anonymize, "anonymize", false, "Mask the last octet of hop addresses (e.g. 10.0.0.x), or the interface identifier of IPv6 ones (2001:db8:1:2::x), for sharing traces")
+	rootCmd.Flags().BoolVar(&interleave, "interleave", false, "Spread probes to different hops over time to avoid ICMP rate limits")
+	rootCmd.Flags().IntVar(&giveUp, "give-up", 0, "Stop after this many consecutive unanswered hops (0 probes up to --max-ttl)")
+	rootCmd.Flags().BoolVar(&resolve, "resolve", false, "Look up the host names of hops and print them as host (ip) once known")
+	rootCmd.Flags().BoolVar(&all, "all", false, "Trace every IPv4 address the target resolves to and show which paths differ")
+	rootCmd.Flags().StringVarP(&iface, "interface", "I", "", "Send probes from this interface, given by name (eth0), index (2) or local address (192.0.2.1)")
+	rootCmd.Flags().IntVarP(&tos, "tos", "Q", 0, "Mark probes with this Type-of-Service byte (0-255, DSCP << 2, e.g. 184 for EF), or IPv6 traffic class")
+	rootCmd.Flags().StringVar(&tag, "tag", "", "Run ID to prefix debug logs with and include in JSON/XML output")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug logging")
 	rootCmd.Flags().BoolVar(&trace, "trace", false, "Enable trace logging")
+	rootCmd.Flags().StringVar(&logPath, "log-file", "", "Also log every pong as a JSON line to this file")
+	rootCmd.Flags().IntVar(&logMaxSize, "log-max-size", 10, "Log file size in MB before it is rotated")
+	rootCmd.Flags().IntVar(&logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
+	rootCmd.MarkFlagsMutuallyExclusive("json", "xml", "csv", "influx")
+	rootCmd.MarkFlagsMutuallyExclusive("summary", "json", "xml", "csv", "influx")
+	rootCmd.MarkFlagsMutuallyExclusive("summary", "per-hop", "path", "dot", "all")
 }
 
 // Execute runs the root command
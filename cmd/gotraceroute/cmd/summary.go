@@ -0,0 +1,50 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+// printSummary prints the --summary table of a finished trace, one row per hop with its loss and
+// min/avg/max RTT, "-" standing in for the RTTs of a hop that never replied
+func printSummary(rep *icmpkg.Report) {
+	path := rep.Path()
+	width := len("Address")
+	for _, addr := range path {
+		if len(addr) > width {
+			width = len(addr)
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n%3s  %-*s %6s %5s %9s %9s %9s\n", "TTL", width, "Address", "Loss%", "Sent", "Min", "Avg", "Max")
+	for i, addr := range path {
+		h := newHopOutput(rep.Hops[i], addr)
+		best, avg, worst := "-", "-", "-"
+		if h.Received > 0 {
+			best, avg, worst = summaryRTT(h.Best), summaryRTT(h.Avg), summaryRTT(h.Worst)
+		}
+		fmt.Fprintf(&sb, "%3d  %-*s %5.1f%% %5d %9s %9s %9s\n", h.TTL, width, h.Addr, h.Loss, h.Sent, best, avg, worst)
+	}
+	fmt.Print(sb.String())
+}
+
+// summaryRTT formats an RTT of the --summary table in milliseconds, e.g. 12.345ms
+func summaryRTT(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
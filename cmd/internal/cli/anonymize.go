@@ -0,0 +1,53 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+// Anonymize masks the last octet of an IPv4 address, e.g. 10.0.0.7 becomes 10.0.0.x, or the interface
+// identifier of an IPv6 address, e.g. 2001:db8:1:2::7 becomes 2001:db8:1:2::x, so traces can be shared
+// without revealing exact hosts. Anything that isn't an IP address is returned unchanged.
+func Anonymize(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if ip4 := parsed.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.x", ip4[0], ip4[1], ip4[2])
+	}
+	group := func(i int) uint16 { return binary.BigEndian.Uint16(parsed[2*i:]) }
+	return fmt.Sprintf("%x:%x:%x:%x::x", group(0), group(1), group(2), group(3)) // Keep the /64 prefix.
+}
+
+// AnonymizeProto masks the hop address of pong in place before it is printed or logged, dropping its host
+// name, as well as the quoted source address of a NAT hop, which may be our own public address.
+func AnonymizeProto(pong *icmpkg.Proto) {
+	if pong.Ip4 != "" {
+		pong.Addr, pong.Ip4 = nil, Anonymize(pong.Ip4)
+	}
+	pong.Host = "" // The host name would reveal the address.
+	pong.QuotedSrc = Anonymize(pong.QuotedSrc)
+}
+
+// HostLabel returns the hop of pong as "host (ip)" if its name was resolved, or the IP otherwise.
+func HostLabel(pong *icmpkg.Proto) string {
+	if pong.Host == "" {
+		return pong.Ip4
+	}
+	return fmt.Sprintf("%s (%s)", pong.Host, pong.Ip4)
+}
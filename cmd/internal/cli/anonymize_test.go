@@ -0,0 +1,57 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"testing"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+func TestAnonymize(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.7":        "10.0.0.x",
+		"8.8.8.8":         "8.8.8.x",
+		"::ffff:1.2.3.4":  "1.2.3.x",
+		"2001:db8:1:2::7": "2001:db8:1:2::x",
+		"2001:4860::8888": "2001:4860:0:0::x",
+		"":                "",
+		"private":         "private",
+		"not-an-ip-at-al": "not-an-ip-at-al",
+	}
+	for in, want := range tests {
+		if got := Anonymize(in); got != want {
+			t.Errorf("Anonymize(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestAnonymizeProto(t *testing.T) {
+	pong := &icmpkg.Proto{Ip4: "192.0.2.1", NAT: true, QuotedSrc: "203.0.113.9", Host: "edge.example.net"}
+	AnonymizeProto(pong)
+	if pong.Ip4 != "192.0.2.x" || pong.QuotedSrc != "203.0.113.x" {
+		t.Errorf("AnonymizeProto() = %q, %q; want both masked", pong.Ip4, pong.QuotedSrc)
+	}
+	if pong.Host != "" {
+		t.Errorf("AnonymizeProto() kept host %q", pong.Host)
+	}
+}
+
+func TestHostLabel(t *testing.T) {
+	if got := HostLabel(&icmpkg.Proto{Ip4: "8.8.8.8", Host: "dns.google"}); got != "dns.google (8.8.8.8)" {
+		t.Errorf("HostLabel() = %q; want dns.google (8.8.8.8)", got)
+	}
+	if got := HostLabel(&icmpkg.Proto{Ip4: "10.0.0.1"}); got != "10.0.0.1" {
+		t.Errorf("HostLabel() without host = %q; want 10.0.0.1", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVHeader is the header row of --csv output, one column per protoOutput field
+var CSVHeader = []string{"ttl", "id", "seq", "ip4", "rtt_ms"}
+
+// CSV writes --csv output, flushing every row so it can be followed live
+type CSV struct {
+	w *csv.Writer
+}
+
+// NewCSV returns a CSV writing to out, which has written CSVHeader
+func NewCSV(out io.Writer) *CSV {
+	c := &CSV{w: csv.NewWriter(out)}
+	c.Write(CSVHeader)
+	return c
+}
+
+// Write writes a row and flushes it
+func (c *CSV) Write(record []string) {
+	_ = c.w.Write(record)
+	c.w.Flush()
+}
+
+// CSVRecord returns the row of a probe in CSVHeader's column order, with the RTT as a number of
+// milliseconds, or empty for a timeout
+func CSVRecord(ttl, id, seq int, ip4 string, rtt time.Duration, timeout bool) []string {
+	ms := ""
+	if !timeout {
+		ms = strconv.FormatFloat(float64(rtt)/float64(time.Millisecond), 'f', 3, 64)
+	}
+	return []string{strconv.Itoa(ttl), strconv.Itoa(id), strconv.Itoa(seq), ip4, ms}
+}
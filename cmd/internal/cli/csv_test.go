@@ -0,0 +1,30 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSV(t *testing.T) {
+	var sb strings.Builder
+	c := NewCSV(&sb)
+	c.Write(CSVRecord(1, 7, 0, "10.0.0.1", 12345*time.Microsecond, false))
+	c.Write(CSVRecord(2, 7, 0, "", 0, true))
+	want := "ttl,id,seq,ip4,rtt_ms\n1,7,0,10.0.0.1,12.345\n2,7,0,,\n"
+	if got := sb.String(); got != want {
+		t.Errorf("CSV output = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+// ParseFamilyPolicy parses the --prefer flag: v4, v6, faster, or v6-within=DURATION such as v6-within=20ms.
+// An empty value returns a nil policy, leaving the address family to the resolver
+func ParseFamilyPolicy(s string) (icmpkg.FamilyPolicy, error) {
+	switch s {
+	case "":
+		return nil, nil
+	case "v4":
+		return icmpkg.PreferV4(), nil
+	case "v6":
+		return icmpkg.PreferV6(), nil
+	case "faster":
+		return icmpkg.PreferFaster(), nil
+	}
+	if margin := strings.TrimPrefix(s, "v6-within="); margin != s {
+		d, err := time.ParseDuration(margin)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid --prefer margin %q: want a duration such as 20ms", margin)
+		}
+		return icmpkg.PreferV6Within(d), nil
+	}
+	return nil, fmt.Errorf("invalid --prefer %q: want v4, v6, faster or v6-within=DURATION", s)
+}
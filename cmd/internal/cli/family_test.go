@@ -0,0 +1,51 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFamilyPolicy(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		in         string
+		rtt4, rtt6 time.Duration
+		v6         bool
+	}{
+		{"v4", 50 * ms, 10 * ms, false},
+		{"v6", 10 * ms, 50 * ms, true},
+		{"faster", 10 * ms, 9 * ms, true},
+		{"v6-within=20ms", 10 * ms, 30 * ms, true},
+		{"v6-within=20ms", 10 * ms, 31 * ms, false},
+	}
+	for _, tt := range tests {
+		policy, err := ParseFamilyPolicy(tt.in)
+		if err != nil || policy == nil {
+			t.Errorf("ParseFamilyPolicy(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := policy(tt.rtt4, tt.rtt6); got != tt.v6 {
+			t.Errorf("ParseFamilyPolicy(%q)(%v, %v) = %v; want %v", tt.in, tt.rtt4, tt.rtt6, got, tt.v6)
+		}
+	}
+	if policy, err := ParseFamilyPolicy(""); policy != nil || err != nil {
+		t.Errorf("ParseFamilyPolicy(\"\") = %v, %v; want no policy", policy != nil, err)
+	}
+	for _, in := range []string{"v5", "v6-within=soon", "v6-within=-1ms"} {
+		if _, err := ParseFamilyPolicy(in); err == nil {
+			t.Errorf("ParseFamilyPolicy(%q) returned no error", in)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"net"
+	"strconv"
+)
+
+// interfaceBinder is implemented by pings and traceroutes
+type interfaceBinder interface {
+	Interface(name string) error
+	InterfaceIndex(index int) error
+	SourceAddress(ip string) error
+}
+
+// BindInterface binds op to the interface given by --interface, which may be a name such as eth0, an
+// index such as 2 or a local address such as 192.0.2.1. An empty spec leaves the binding to the kernel.
+func BindInterface(op interfaceBinder, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	if net.ParseIP(spec) != nil {
+		return op.SourceAddress(spec)
+	}
+	if index, err := strconv.Atoi(spec); err == nil {
+		return op.InterfaceIndex(index)
+	}
+	return op.Interface(spec)
+}
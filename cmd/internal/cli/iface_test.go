@@ -0,0 +1,52 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"strconv"
+	"testing"
+)
+
+// fakeBinder records which binding BindInterface chose.
+type fakeBinder struct{ bound string }
+
+func (b *fakeBinder) Interface(name string) error {
+	b.bound = "name " + name
+	return nil
+}
+
+func (b *fakeBinder) InterfaceIndex(index int) error {
+	b.bound = "index " + strconv.Itoa(index)
+	return nil
+}
+
+func (b *fakeBinder) SourceAddress(ip string) error {
+	b.bound = "address " + ip
+	return nil
+}
+
+func TestBindInterface(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"eth0":      "name eth0",
+		"2":         "index 2",
+		"192.0.2.1": "address 192.0.2.1",
+		"fe80::1":   "address fe80::1",
+	}
+	for spec, want := range tests {
+		b := &fakeBinder{}
+		if err := BindInterface(b, spec); err != nil || b.bound != want {
+			t.Errorf("BindInterface(%q) bound %q, %v; want %q", spec, b.bound, err, want)
+		}
+	}
+}
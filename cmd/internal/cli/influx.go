@@ -0,0 +1,83 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+// influxMeasurement is the measurement of every line written by --influx
+const influxMeasurement = "icmp"
+
+// influxEscaper escapes the characters that are special in line protocol tag values
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxLine builds a line protocol line from ordered tag and field pairs, skipping empty tag values
+func influxLine(tags, fields []string, ts time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(influxMeasurement)
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] != "" {
+			sb.WriteString("," + tags[i] + "=" + influxEscaper.Replace(tags[i+1]))
+		}
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		sb.WriteString(sep + fields[i] + "=" + fields[i+1])
+	}
+	sb.WriteString(" " + strconv.FormatInt(ts.UnixNano(), 10))
+	return sb.String()
+}
+
+// influxMs formats a duration as a float field in milliseconds
+func influxMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+}
+
+// InfluxProbe formats a finished probe as an InfluxDB line protocol line, e.g.
+// "icmp,target=8.8.8.8,type=probe,ip=8.8.8.8 rtt=12.5,loss=0,seq=3i 1700000000000000000". The TTL is
+// tagged in traceroute mode. A timeout has loss=100 and no rtt, so loss averages to a percentage
+func InfluxProbe(target string, pong *icmpkg.Proto) string {
+	ttl := ""
+	if pong.TTL > 0 {
+		ttl = strconv.Itoa(pong.TTL)
+	}
+	tags := []string{"target", target, "type", "probe", "ip", pong.Ip4, "ttl", ttl, "tag", pong.Tag}
+	fields := []string{"rtt", influxMs(pong.Rtt), "loss", "0"}
+	if pong.IsTimeout() {
+		fields = []string{"loss", "100"}
+	}
+	fields = append(fields, "seq", strconv.Itoa(pong.Seq)+"i")
+	return influxLine(tags, fields, pong.Time)
+}
+
+// InfluxSummary formats the statistics of a run as a line tagged type=summary, e.g.
+// "icmp,target=8.8.8.8,type=summary rtt=12.5,rtt_min=11,rtt_max=14,loss=0,sent=3i,received=3i <ts>"
+func InfluxSummary(target, tag string, st icmpkg.Statistics, ts time.Time) string {
+	tags := []string{"target", target, "type", "summary", "tag", tag}
+	var fields []string
+	if st.Received > 0 {
+		fields = append(fields, "rtt", influxMs(st.Avg), "rtt_min", influxMs(st.Min), "rtt_max", influxMs(st.Max))
+	}
+	fields = append(fields,
+		"loss", strconv.FormatFloat(st.Loss, 'f', -1, 64),
+		"sent", strconv.Itoa(st.Transmitted)+"i",
+		"received", strconv.Itoa(st.Received)+"i")
+	return influxLine(tags, fields, ts)
+}
@@ -0,0 +1,42 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-the-way/icmpkg"
+)
+
+func TestInfluxProbe(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	reply := &icmpkg.Proto{TTL: 3, Seq: 2, Ip4: "192.0.2.1", Rtt: 12500 * time.Microsecond, Time: ts, Tag: "run 1"}
+	want := `icmp,target=example.com,type=probe,ip=192.0.2.1,ttl=3,tag=run\ 1 rtt=12.5,loss=0,seq=2i 1700000000000000000`
+	if got := InfluxProbe("example.com", reply); got != want {
+		t.Errorf("InfluxProbe(reply) = %q; want %q", got, want)
+	}
+}
+
+func TestInfluxSummary(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	st := icmpkg.Statistics{Transmitted: 4, Received: 3, Loss: 25, Min: 11 * time.Millisecond, Avg: 12500 * time.Microsecond, Max: 14 * time.Millisecond}
+	want := "icmp,target=8.8.8.8,type=summary rtt=12.5,rtt_min=11,rtt_max=14,loss=25,sent=4i,received=3i 1700000000000000000"
+	if got := InfluxSummary("8.8.8.8", "", st, ts); got != want {
+		t.Errorf("InfluxSummary() = %q; want %q", got, want)
+	}
+	want = "icmp,target=8.8.8.8,type=summary loss=100,sent=2i,received=0i 1700000000000000000"
+	if got := InfluxSummary("8.8.8.8", "", icmpkg.Statistics{Transmitted: 2, Loss: 100}, ts); got != want {
+		t.Errorf("InfluxSummary(no replies) = %q; want %q", got, want)
+	}
+}
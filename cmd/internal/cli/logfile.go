@@ -0,0 +1,123 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli holds helpers shared by the goping, gotraceroute and gomtr command-line tools.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RotatingFile is a log file that is rotated once it grows beyond a maximum size. Rotated files are
+// renamed to path.1, path.2, ... with at most maxBackups of them kept.
+type RotatingFile struct {
+	path       string      // Path of the active log file.
+	maxSize    int64       // Size in bytes after which the file is rotated.
+	maxBackups int         // Number of rotated files to keep.
+	mu         *sync.Mutex // Mutex serializing writes and rotation.
+	f          *os.File    // Active log file.
+	size       int64       // Current size of the active log file.
+}
+
+// OpenRotatingFile opens (appending to) the log file at path, rotating it once it exceeds maxSize bytes.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups, mu: &sync.Mutex{}}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OpenLog opens the rotating log file configured by the --log-file flags, or returns nil if path is empty.
+func OpenLog(path string, maxSizeMB, maxBackups int) (*RotatingFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return OpenRotatingFile(path, int64(maxSizeMB)<<20, maxBackups)
+}
+
+// Write appends p to the log file, rotating first if p would push it past the maximum size.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the active log file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// open opens the active log file for appending and records its current size.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, moves the active file to path.1 and reopens it.
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(r.path, 0); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// logRecord is a single structured log line.
+type logRecord struct {
+	Time   time.Time `json:"time"`   // Time the pong was logged.
+	Target string    `json:"target"` // Target address of the operation.
+	Pong   any       `json:"pong"`   // Serialized pong.
+}
+
+// LogJSON writes pong for target as a single JSON line to w, if w is non-nil.
+func LogJSON(w *RotatingFile, target string, pong any) {
+	if w == nil {
+		return
+	}
+	data, err := json.Marshal(logRecord{Time: time.Now(), Target: target, Pong: pong})
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,113 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NumberFormat renders numbers with a locale's decimal separator and digit grouping.
+type NumberFormat struct {
+	Decimal string // Decimal separator, e.g. "." or ",".
+	Group   string // Thousands separator; empty disables grouping.
+}
+
+// DefaultNumberFormat is the plain format used when no locale is given, matching the C locale.
+var DefaultNumberFormat = NumberFormat{Decimal: "."}
+
+// numberFormats maps language and language_REGION names to their number formats.
+var numberFormats = map[string]NumberFormat{
+	"c":     DefaultNumberFormat,
+	"posix": DefaultNumberFormat,
+	"en":    {Decimal: ".", Group: ","},
+	"ja":    {Decimal: ".", Group: ","},
+	"ko":    {Decimal: ".", Group: ","},
+	"zh":    {Decimal: ".", Group: ","},
+	"de":    {Decimal: ",", Group: "."},
+	"es":    {Decimal: ",", Group: "."},
+	"it":    {Decimal: ",", Group: "."},
+	"nl":    {Decimal: ",", Group: "."},
+	"pt":    {Decimal: ",", Group: "."},
+	"tr":    {Decimal: ",", Group: "."},
+	"fr":    {Decimal: ",", Group: " "},
+	"ru":    {Decimal: ",", Group: " "},
+	"pl":    {Decimal: ",", Group: " "},
+	"sv":    {Decimal: ",", Group: " "},
+	"de_ch": {Decimal: ".", Group: "’"},
+}
+
+// ParseLocale returns the number format for a locale name such as "de", "de_DE.UTF-8" or "fr-FR". An
+// empty name keeps DefaultNumberFormat and "auto" reads LC_ALL, LC_NUMERIC or LANG, in that order.
+func ParseLocale(name string) (NumberFormat, error) {
+	if name == "auto" {
+		name = ""
+		for _, env := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+	if name == "" {
+		return DefaultNumberFormat, nil
+	}
+	tag := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+	if i := strings.IndexAny(tag, ".@"); i >= 0 {
+		tag = tag[:i] // Drop the codeset and modifier, e.g. ".UTF-8".
+	}
+	if f, ok := numberFormats[tag]; ok {
+		return f, nil
+	}
+	if i := strings.IndexByte(tag, '_'); i >= 0 {
+		if f, ok := numberFormats[tag[:i]]; ok {
+			return f, nil // Fall back to the language without the region.
+		}
+	}
+	return DefaultNumberFormat, fmt.Errorf("unsupported locale %q", name)
+}
+
+// Int formats n with digit grouping.
+func (f NumberFormat) Int(n int) string {
+	return f.group(strconv.Itoa(n))
+}
+
+// Float formats v with prec decimals, the locale's decimal separator and digit grouping.
+func (f NumberFormat) Float(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	frac := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], f.Decimal+s[i+1:]
+	}
+	return f.group(s) + frac
+}
+
+// group inserts the thousands separator into a string of digits with an optional sign.
+func (f NumberFormat) group(digits string) string {
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if f.Group == "" || len(digits) <= 3 {
+		return sign + digits
+	}
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteString(f.Group)
+		}
+		sb.WriteRune(d)
+	}
+	return sb.String()
+}
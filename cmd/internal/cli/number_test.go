@@ -0,0 +1,62 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import "testing"
+
+func TestParseLocale(t *testing.T) {
+	tests := map[string]NumberFormat{
+		"":            DefaultNumberFormat,
+		"C":           DefaultNumberFormat,
+		"en_US.UTF-8": {Decimal: ".", Group: ","},
+		"de":          {Decimal: ",", Group: "."},
+		"de-AT":       {Decimal: ",", Group: "."},
+		"de_CH.UTF-8": {Decimal: ".", Group: "’"},
+		"fr_FR@euro":  {Decimal: ",", Group: " "},
+	}
+	for in, want := range tests {
+		got, err := ParseLocale(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLocale(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLocale("xx_YY"); err == nil {
+		t.Error("ParseLocale(xx_YY) returned no error")
+	}
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_NUMERIC", "de_DE.UTF-8")
+	if got, _ := ParseLocale("auto"); got.Decimal != "," {
+		t.Errorf("ParseLocale(auto) = %+v; want the de format", got)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	de, _ := ParseLocale("de")
+	tests := []struct {
+		got, want string
+	}{
+		{DefaultNumberFormat.Int(1234567), "1234567"},
+		{DefaultNumberFormat.Float(12.345, 1), "12.3"},
+		{de.Int(999), "999"},
+		{de.Int(1234567), "1.234.567"},
+		{de.Int(-1234), "-1.234"},
+		{de.Float(1234.5678, 3), "1.234,568"},
+		{de.Float(0.5, 1), "0,5"},
+		{de.Float(33.3333, 0), "33"},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("case %d: got %q; want %q", i, tt.got, tt.want)
+		}
+	}
+}
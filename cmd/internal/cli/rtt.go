@@ -0,0 +1,23 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import "time"
+
+// FloorRTT returns 0 for a reply RTT below floor, so that near-zero RTTs of local hops show up as 0 rather
+// than as noisy tiny numbers. A floor of 0 leaves rtt unchanged
+func FloorRTT(rtt, floor time.Duration) time.Duration {
+	if rtt < floor {
+		return 0
+	}
+	return rtt
+}
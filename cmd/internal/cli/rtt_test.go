@@ -0,0 +1,34 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFloorRTT(t *testing.T) {
+	tests := []struct {
+		rtt, floor, want time.Duration
+	}{
+		{300 * time.Microsecond, 0, 300 * time.Microsecond},
+		{300 * time.Microsecond, time.Millisecond, 0},
+		{time.Millisecond, time.Millisecond, time.Millisecond},
+		{12 * time.Millisecond, time.Millisecond, 12 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := FloorRTT(tt.rtt, tt.floor); got != tt.want {
+			t.Errorf("FloorRTT(%v, %v) = %v; want %v", tt.rtt, tt.floor, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import "syscall"
+
+// setDontFragment makes the socket send every packet with the Don't Fragment bit set and never fragment
+// locally, so oversized probes are reported by the hop whose link is too small. Like tracepath, it ignores
+// the path MTU the kernel cached from earlier reports, so sizes above it can still be probed.
+func setDontFragment(c syscall.RawConn) error {
+	var err error
+	cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// dotSource is the ID of the node every path starts from, the host running the trace.
+const dotSource = "source"
+
+// dotEdge is a transition between the nodes answering the same probe round at consecutive TTLs.
+type dotEdge struct{ from, to string }
+
+// dotGraph collects the nodes and edges of the paths of one or more traceroute reports.
+type dotGraph struct {
+	labels  map[string]string // Label of every node, keyed by node ID.
+	targets map[string]bool   // Node IDs of the trace targets, drawn highlighted.
+	edges   map[dotEdge]int   // Number of times every transition was observed.
+}
+
+// WriteDOT writes the paths of one or more traceroute reports to w as a Graphviz DOT digraph, to be
+// rendered e.g. with `dot -Tpng`. Every address answering a probe is a node, and every transition from the
+// address answering a probe round at one TTL to the one answering the same round, i.e. sequence number,
+// at the next TTL is an edge labeled with how often it was observed, so ECMP branches show up as forks.
+// Unanswered probes are drawn as a "*" node per TTL to keep the path connected. Passing the reports of
+// several runs, e.g. from a monitoring loop, merges them into one graph.
+func WriteDOT(w io.Writer, reports ...*Report) error {
+	g := &dotGraph{labels: map[string]string{dotSource: dotSource}, targets: make(map[string]bool), edges: make(map[dotEdge]int)}
+	for _, r := range reports {
+		g.add(r)
+	}
+	return g.write(w)
+}
+
+// add records the nodes and transitions of a report.
+func (g *dotGraph) add(r *Report) {
+	r.mu.Lock()         // Lock for thread-safe hop access.
+	defer r.mu.Unlock() // Unlock after hop access.
+	if r.Ip4 != "" {
+		g.targets[r.Ip4] = true
+	}
+	prev := make(map[int]string) // Node answering each probe round at the previous TTL.
+	for _, h := range r.Hops {
+		cur := make(map[int]string)
+		for _, pto := range h.Probes {
+			node := g.node(pto)
+			cur[pto.Seq] = node
+			from, ok := prev[pto.Seq]
+			if !ok {
+				from = dotSource // The round starts here, or its previous TTL wasn't probed.
+			}
+			g.edges[dotEdge{from, node}]++
+		}
+		prev = cur
+	}
+}
+
+// node returns the ID of the node answering pto, adding it to the graph.
+func (g *dotGraph) node(pto *Proto) string {
+	if pto.IsTimeout() || pto.Ip4 == "" {
+		id := "*" + strconv.Itoa(pto.TTL) // A separate unanswered node per TTL.
+		g.labels[id] = "*"
+		return id
+	}
+	label := pto.Ip4
+	if pto.Host != "" {
+		label = pto.Host + "\n" + pto.Ip4 // Name the hop if it was resolved.
+	}
+	g.labels[pto.Ip4] = label
+	return pto.Ip4
+}
+
+// write renders the graph in DOT, with nodes and edges sorted so the output is deterministic.
+func (g *dotGraph) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph traceroute {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	ids := make([]string, 0, len(g.labels))
+	for id := range g.labels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		attrs := "label=" + strconv.Quote(g.labels[id])
+		switch {
+		case id == dotSource:
+			attrs += ", shape=ellipse"
+		case g.targets[id]:
+			attrs += ", style=bold"
+		case g.labels[id] == "*":
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(bw, "\t%s [%s];\n", strconv.Quote(id), attrs)
+	}
+	edges := make([]dotEdge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	for _, e := range edges {
+		fmt.Fprintf(bw, "\t%s -> %s [label=\"%d\"];\n", strconv.Quote(e.from), strconv.Quote(e.to), g.edges[e])
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
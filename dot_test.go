@@ -0,0 +1,56 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteDOT(t *testing.T) {
+	// Two probe rounds split over equal-cost paths at TTL 2, the second losing its TTL 3 reply.
+	r := &Report{mu: &sync.Mutex{}, Ip4: "8.8.8.8"}
+	r.add(pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond))
+	r.add(pongProto(1, 1, 1, nil, "10.0.0.1", time.Millisecond))
+	r.add(pongProto(2, 2, 0, nil, "10.0.1.1", time.Millisecond))
+	r.add(pongProto(2, 2, 1, nil, "10.0.2.1", time.Millisecond))
+	r.add(pongProto(3, 3, 0, nil, "8.8.8.8", time.Millisecond))
+	r.add(timeoutProto(3, 3, 1))
+	r.finish()
+
+	var sb strings.Builder
+	if err := WriteDOT(&sb, r, r); err != nil {
+		t.Fatalf("WriteDOT() error: %v", err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		"digraph traceroute {\n",
+		"\t\"8.8.8.8\" [label=\"8.8.8.8\", style=bold];\n",
+		"\t\"*3\" [label=\"*\", style=dashed];\n",
+		"\t\"source\" -> \"10.0.0.1\" [label=\"4\"];\n",
+		"\t\"10.0.0.1\" -> \"10.0.1.1\" [label=\"2\"];\n",
+		"\t\"10.0.0.1\" -> \"10.0.2.1\" [label=\"2\"];\n",
+		"\t\"10.0.1.1\" -> \"8.8.8.8\" [label=\"2\"];\n",
+		"\t\"10.0.2.1\" -> \"*3\" [label=\"2\"];\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteDOT() output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "->") != 5 {
+		t.Errorf("WriteDOT() wrote %d edges; want 5:\n%s", strings.Count(got, "->"), got)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// familyProbes is the number of pings SelectFamily sends to each address family.
+const familyProbes = 3
+
+// FamilyPolicy decides which address family of a dual-stack host to measure from the average RTTs to its
+// IPv4 and IPv6 address, reporting true for IPv6. It is only consulted when both families answered.
+type FamilyPolicy func(rtt4, rtt6 time.Duration) (v6 bool)
+
+// PreferV4 measures IPv4 whenever it answers.
+func PreferV4() FamilyPolicy { return func(_, _ time.Duration) bool { return false } }
+
+// PreferV6 measures IPv6 whenever it answers.
+func PreferV6() FamilyPolicy { return func(_, _ time.Duration) bool { return true } }
+
+// PreferFaster measures the family with the lower RTT, IPv4 on a tie.
+func PreferFaster() FamilyPolicy { return func(rtt4, rtt6 time.Duration) bool { return rtt6 < rtt4 } }
+
+// PreferV6Within measures IPv6 unless it is more than margin slower than IPv4, e.g. PreferV6Within(20ms)
+// for "measure IPv6 if it's within 20ms of IPv4, else IPv4".
+func PreferV6Within(margin time.Duration) FamilyPolicy {
+	return func(rtt4, rtt6 time.Duration) bool { return rtt6-rtt4 <= margin }
+}
+
+// FamilyDecision records which address of a host SelectFamily chose to measure, and why.
+type FamilyDecision struct {
+	Host    string        `json:"host"`          // Host as given.
+	Ip4     string        `json:"ip4,omitempty"` // IPv4 address compared; empty if the host has none.
+	Ip6     string        `json:"ip6,omitempty"` // IPv6 address compared; empty if the host has none.
+	Rtt4    time.Duration `json:"rtt4"`          // Average RTT to Ip4; 0 if it didn't answer.
+	Rtt6    time.Duration `json:"rtt6"`          // Average RTT to Ip6; 0 if it didn't answer.
+	V6      bool          `json:"v6"`            // Whether IPv6 was chosen.
+	Address string        `json:"address"`       // Chosen address, to pass to Ping or Traceroute.
+	Reason  string        `json:"reason"`        // Why the family was chosen.
+}
+
+// String returns a one-line summary of the decision, e.g. for logging it next to the measurement.
+func (d FamilyDecision) String() string {
+	if d.Ip4 == "" || d.Ip6 == "" {
+		return fmt.Sprintf("%s: measuring %s (%s)", d.Host, d.Address, d.Reason) // Nothing was compared.
+	}
+	return fmt.Sprintf("%s: measuring %s (%s; ipv4 %s, ipv6 %s)", d.Host, d.Address, d.Reason, familyRttString(d.Rtt4), familyRttString(d.Rtt6))
+}
+
+// familyRttString formats an RTT compared by SelectFamily, where 0 means no reply.
+func familyRttString(rtt time.Duration) string {
+	if rtt == 0 {
+		return "no reply"
+	}
+	return rtt.String()
+}
+
+// SelectFamily picks the address of host to measure. If host has both an IPv4 and an IPv6 address, each
+// is pinged three times concurrently and policy decides between them from the average RTTs, e.g.
+// SelectFamily(host, PreferV6Within(20*time.Millisecond)). If only one family answers, or host has only
+// one, that family is chosen; if neither answers, IPv4 is. It returns an error if host doesn't resolve.
+func SelectFamily(host string, policy FamilyPolicy) (FamilyDecision, error) {
+	return selectFamily(host, policy, net.LookupIP, familyRtt)
+}
+
+// selectFamily implements SelectFamily with the resolver and prober replaceable for tests.
+func selectFamily(host string, policy FamilyPolicy, lookup func(string) ([]net.IP, error), probe func(addr string) time.Duration) (FamilyDecision, error) {
+	d := FamilyDecision{Host: host}
+	ips, err := lookup(host)
+	if err != nil {
+		return d, fmt.Errorf("icmpkg: cannot resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil && d.Ip4 == "" {
+			d.Ip4 = ip4.String() // Compare the first IPv4 address.
+		} else if ip4 == nil && d.Ip6 == "" {
+			d.Ip6 = ip.String() // Compare the first IPv6 address.
+		}
+	}
+	switch {
+	case d.Ip4 == "" && d.Ip6 == "":
+		return d, fmt.Errorf("icmpkg: cannot resolve %s", host)
+	case d.Ip6 == "":
+		return d.choose(false, "no ipv6 address"), nil
+	case d.Ip4 == "":
+		return d.choose(true, "no ipv4 address"), nil
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() { defer wg.Done(); d.Rtt4 = probe(d.Ip4) }()
+	go func() { defer wg.Done(); d.Rtt6 = probe(d.Ip6) }()
+	wg.Wait()
+	switch {
+	case d.Rtt4 == 0 && d.Rtt6 == 0:
+		return d.choose(false, "neither family answered"), nil
+	case d.Rtt6 == 0:
+		return d.choose(false, "ipv6 didn't answer"), nil
+	case d.Rtt4 == 0:
+		return d.choose(true, "ipv4 didn't answer"), nil
+	}
+	return d.choose(policy(d.Rtt4, d.Rtt6), "policy"), nil
+}
+
+// choose records the chosen family and the reason for it.
+func (d FamilyDecision) choose(v6 bool, reason string) FamilyDecision {
+	d.V6, d.Address, d.Reason = v6, d.Ip4, reason
+	if v6 {
+		d.Address = d.Ip6
+	}
+	return d
+}
+
+// familyRtt returns the average RTT of a few pings to addr, or 0 if none was answered.
+func familyRtt(addr string) time.Duration {
+	p := PingDuration(addr, familyProbes, time.Millisecond*500, time.Millisecond*500)
+	p.Interval(time.Millisecond * 100) // Don't hold up the measurement for long.
+	p.Run()
+	return p.Stats().Avg
+}
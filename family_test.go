@@ -0,0 +1,79 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSelectFamily(t *testing.T) {
+	dual := func(string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, nil
+	}
+	rtts := func(rtt4, rtt6 time.Duration) func(string) time.Duration {
+		return func(addr string) time.Duration {
+			if addr == "192.0.2.1" {
+				return rtt4
+			}
+			return rtt6
+		}
+	}
+	ms := time.Millisecond
+	tests := []struct {
+		name       string
+		policy     FamilyPolicy
+		rtt4, rtt6 time.Duration
+		want       string
+		reason     string
+	}{
+		{"v6 within margin", PreferV6Within(20 * ms), 10 * ms, 25 * ms, "2001:db8::1", "policy"},
+		{"v6 beyond margin", PreferV6Within(20 * ms), 10 * ms, 31 * ms, "192.0.2.1", "policy"},
+		{"prefer v4", PreferV4(), 50 * ms, 10 * ms, "192.0.2.1", "policy"},
+		{"prefer v6", PreferV6(), 10 * ms, 50 * ms, "2001:db8::1", "policy"},
+		{"faster", PreferFaster(), 10 * ms, 5 * ms, "2001:db8::1", "policy"},
+		{"v6 silent", PreferV6(), 10 * ms, 0, "192.0.2.1", "ipv6 didn't answer"},
+		{"v4 silent", PreferV4(), 0, 10 * ms, "2001:db8::1", "ipv4 didn't answer"},
+		{"both silent", PreferV6(), 0, 0, "192.0.2.1", "neither family answered"},
+	}
+	for _, tt := range tests {
+		d, err := selectFamily("dual.example", tt.policy, dual, rtts(tt.rtt4, tt.rtt6))
+		if err != nil || d.Address != tt.want || d.Reason != tt.reason || d.V6 != (tt.want == "2001:db8::1") {
+			t.Errorf("%s: selectFamily() = %+v, %v; want %s (%s)", tt.name, d, err, tt.want, tt.reason)
+		}
+		if tt.name == "v6 silent" && d.String() != "dual.example: measuring 192.0.2.1 (ipv6 didn't answer; ipv4 10ms, ipv6 no reply)" {
+			t.Errorf("%s: String() = %q", tt.name, d)
+		}
+		if d.Rtt4 != tt.rtt4 || d.Rtt6 != tt.rtt6 {
+			t.Errorf("%s: recorded RTTs %v/%v; want %v/%v", tt.name, d.Rtt4, d.Rtt6, tt.rtt4, tt.rtt6)
+		}
+	}
+}
+
+func TestSelectFamilySingleStack(t *testing.T) {
+	probe := func(string) time.Duration {
+		t.Error("a single-stack host was probed")
+		return 0
+	}
+	d, err := selectFamily("127.0.0.1", PreferV6(), net.LookupIP, probe)
+	if err != nil || d.Address != "127.0.0.1" || d.V6 || d.Reason != "no ipv6 address" {
+		t.Errorf("selectFamily(127.0.0.1) = %+v, %v; want 127.0.0.1", d, err)
+	}
+	failing := func(string) ([]net.IP, error) { return nil, errors.New("no such host") }
+	if _, err := selectFamily("invalid.example", PreferV6(), failing, probe); err == nil {
+		t.Error("selectFamily() of an unresolvable host returned no error")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// firstHopProbes is the number of TTL 1 probes FirstHop sends before giving up.
+const firstHopProbes = 3
+
+// FirstHop returns the router a packet to target leaves through, i.e. the gateway toward target, by
+// sending up to three probes with TTL 1 and returning the address of the first hop that answers. If target
+// is on the local network, target itself answers and is returned.
+func FirstHop(target string) (net.IP, error) {
+	tr := TracerouteDuration(target, 1, firstHopProbes, time.Millisecond*500, time.Millisecond*500)
+	if tr.Ip4() == "" {
+		return nil, fmt.Errorf("icmpkg: cannot resolve %s", target)
+	}
+	var hop net.IP
+	tr.PongHandler(func(pong *Proto) {
+		if pong.IsTimeout() || hop != nil {
+			return
+		}
+		hop = net.ParseIP(pong.Ip4)
+		tr.Stop() // One answer is enough.
+	})
+	tr.Run()
+	if hop == nil {
+		return nil, fmt.Errorf("icmpkg: no reply from the first hop toward %s", target)
+	}
+	return hop, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFirstHop(t *testing.T) {
+	skipWithoutRawSocket(t)
+	// The loopback address is on the local network, so it answers the TTL 1 probe itself.
+	hop, err := FirstHop("127.0.0.1")
+	if err != nil {
+		t.Fatalf("FirstHop(127.0.0.1) error: %v", err)
+	}
+	if !hop.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("FirstHop(127.0.0.1) = %v; want 127.0.0.1", hop)
+	}
+}
+
+func TestFirstHopUnresolved(t *testing.T) {
+	if _, err := FirstHop("invalid..host"); err == nil {
+		t.Error("FirstHop() of an unresolvable host returned no error")
+	}
+}
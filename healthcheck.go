@@ -0,0 +1,81 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// Criteria defines when a Healthcheck passes: at least Min of Of probes must be answered within MaxRTT.
+type Criteria struct {
+	Min    int           // Minimum number of successful probes; defaults to 1.
+	Of     int           // Number of probes to send; defaults to Min.
+	MaxRTT time.Duration // Replies slower than this don't count as successes; 0 accepts any reply.
+}
+
+// HealthResult is the outcome of a Healthcheck.
+type HealthResult struct {
+	Pass       bool       `json:"pass"`       // Whether the criteria were met.
+	Successes  int        `json:"successes"`  // Number of replies within MaxRTT.
+	Slow       int        `json:"slow"`       // Number of replies slower than MaxRTT.
+	Statistics Statistics `json:"statistics"` // Statistics over all probes.
+	Reason     string     `json:"reason"`     // Why the check failed; empty if it passed.
+}
+
+// Healthcheck pings target Of times and reports whether at least Min replies arrived within MaxRTT, e.g.
+// Healthcheck("10.0.0.1", Criteria{Min: 3, Of: 5, MaxRTT: 50 * time.Millisecond}) for a 3-of-5 SLO.
+// Replies are awaited for the default read duration (see SetDefaultReadDuration), or MaxRTT if longer.
+func Healthcheck(target string, c Criteria) HealthResult {
+	if c.Min <= 0 {
+		c.Min = 1 // Any reply by default.
+	}
+	if c.Of < c.Min {
+		c.Of = c.Min // Send at least as many probes as must succeed.
+	}
+	writeDur, readDur := defaultDurations()
+	if c.MaxRTT > readDur {
+		readDur = c.MaxRTT // Don't time out replies that would still count.
+	}
+	var res HealthResult
+	p := PingDuration(target, c.Of, writeDur, readDur)
+	p.ProbeHook(func(pto *Proto) { res.add(c, pto) })
+	p.Run()
+	res.Statistics = p.Stats()
+	res.Pass = res.Successes >= c.Min
+	switch {
+	case res.Pass:
+	case p.Ip4() == "":
+		res.Reason = fmt.Sprintf("cannot resolve %s", target)
+	case res.Slow > 0:
+		res.Reason = fmt.Sprintf("%d of %d probes succeeded within %v, %d replied too slowly; need %d", res.Successes, c.Of, c.MaxRTT, res.Slow, c.Min)
+	default:
+		res.Reason = fmt.Sprintf("%d of %d probes succeeded; need %d", res.Successes, c.Of, c.Min)
+	}
+	return res
+}
+
+// add counts a finished probe against the criteria.
+func (res *HealthResult) add(c Criteria, pto *Proto) {
+	switch {
+	case pto.Dup || pto.OutOfOrder:
+		// The probe was counted with its first reply or as a timeout already.
+	case pto.IsTimeout():
+	case c.MaxRTT > 0 && pto.Rtt > c.MaxRTT:
+		res.Slow++
+	default:
+		res.Successes++
+	}
+}
+
+// Alive reports whether target answers any of three pings.
+func Alive(target string) bool { return Healthcheck(target, Criteria{Min: 1, Of: 3}).Pass }
@@ -0,0 +1,52 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthcheck(t *testing.T) {
+	skipWithoutRawSocket(t)
+
+	res := Healthcheck("127.0.0.1", Criteria{Min: 2, Of: 3, MaxRTT: time.Second})
+	if !res.Pass || res.Successes != 3 || res.Reason != "" {
+		t.Errorf("loopback: %+v; want a pass with 3 successes", res)
+	}
+	res = Healthcheck("127.0.0.1", Criteria{Min: 2, Of: 2, MaxRTT: time.Nanosecond})
+	if res.Pass || res.Slow != 2 || res.Reason == "" {
+		t.Errorf("loopback within 1ns: %+v; want a failure with 2 slow replies", res)
+	}
+	if res.Statistics.Transmitted != 2 || res.Statistics.Received != 2 {
+		t.Errorf("statistics = %+v; want 2 transmitted and received", res.Statistics)
+	}
+}
+
+func TestHealthResultAdd(t *testing.T) {
+	c := Criteria{Min: 3, Of: 5, MaxRTT: 50 * time.Millisecond}
+	var res HealthResult
+	for seq := 0; seq < 2; seq++ {
+		res.add(c, pongProto(0, 1, seq, nil, "10.0.0.1", 10*time.Millisecond))
+		dup := pongProto(0, 1, seq, nil, "10.0.0.1", 12*time.Millisecond)
+		dup.Dup = true
+		res.add(c, dup)
+	}
+	res.add(c, timeoutProto(0, 1, 2))
+	late := pongProto(0, 1, 2, nil, "10.0.0.1", 10*time.Millisecond)
+	late.OutOfOrder = true
+	res.add(c, late)
+	if res.Successes != 2 || res.Slow != 0 {
+		t.Errorf("Successes, Slow = %d, %d; want 2, 0 with duplicates and out of order replies left out", res.Successes, res.Slow)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	m := MultiPing([]string{"8.8.8.8", "1.1.1.1"}, 3)
+	m.pong(0, pongProto(0, 1, 0, nil, "8.8.8.8", 20*time.Millisecond))
+	m.pong(0, timeoutProto(0, 1, 1))
+	m.pong(1, pongProto(0, 2, 0, nil, "1.1.1.1", 5*time.Millisecond))
+
+	rec := httptest.NewRecorder()
+	StatusHandler(m).ServeHTTP(rec, httptest.NewRequest("GET", "/ping-status", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q; want application/json", ct)
+	}
+	var stats []TargetStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("body is not valid JSON: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d targets; want 2", len(stats))
+	}
+	if st := stats[0]; st.Target != "8.8.8.8" || st.Transmitted != 2 || st.Received != 1 || st.Loss != 50 || st.LastRtt != 20*time.Millisecond {
+		t.Errorf("stats[0] = %+v; want 2 transmitted, 1 received, 50%% loss, last 20ms", st)
+	}
+	if st := stats[1]; st.Target != "1.1.1.1" || st.Loss != 0 {
+		t.Errorf("stats[1] = %+v; want 1.1.1.1 with no loss", st)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// TargetStats summarizes the latest results for a single target of a multi-target ping.
+type TargetStats struct {
+	Target      string        `json:"target"`      // Target address as given.
+	Ip4         string        `json:"ip4"`         // Resolved IPv4 address.
+	Transmitted int           `json:"transmitted"` // Number of packets sent.
+	Received    int           `json:"received"`    // Number of replies received.
+	Loss        float64       `json:"loss"`        // Packet loss percentage.
+	LastRtt     time.Duration `json:"last_rtt"`    // Round-trip time of the latest reply.
+	Updated     time.Time     `json:"updated"`     // Time of the latest pong, reply or timeout.
+}
+
+// multi runs ping operations against several targets concurrently.
+type multi struct {
+	pings       []*ping                          // Ping instances, one per target.
+	stats       []TargetStats                    // Latest statistics, one per target.
+	mu          *sync.Mutex                      // Mutex for thread-safe access to stats.
+	pongHandler func(target string, pong *Proto) // Optional callback for handling pong responses.
+}
+
+// MultiPing creates a multi-target ping with default write and read durations of 500ms, see
+// SetDefaultWriteDuration.
+func MultiPing(targets []string, count int) *multi {
+	// Initialize multi-target ping with default durations for write and read operations.
+	writeDur, readDur := defaultDurations()
+	return MultiPingDuration(targets, count, writeDur, readDur)
+}
+
+// MultiPingDuration creates a multi-target ping with specified write and read durations.
+func MultiPingDuration(targets []string, count int, writeDur, readDur time.Duration) *multi {
+	return newMulti(targets, func(target string) *traceroute { return PingDuration(target, count, writeDur, readDur) })
+}
+
+// TracerouteAll creates a traceroute to every IPv4 address host resolves to, with default write and read
+// durations of 500ms (see SetDefaultWriteDuration), so paths to a host served from several addresses can
+// be compared. Pong handlers and statistics are labeled with the address rather than the host.
+func TracerouteAll(host string, maxTTL, count int) *multi {
+	writeDur, readDur := defaultDurations()
+	return TracerouteAllDuration(host, maxTTL, count, writeDur, readDur)
+}
+
+// TracerouteAllDuration creates a traceroute to every IPv4 address of host with specified write and read
+// durations.
+func TracerouteAllDuration(host string, maxTTL, count int, writeDur, readDur time.Duration) *multi {
+	return newMulti(ResolveAll(host), func(target string) *traceroute {
+		return TracerouteDuration(target, maxTTL, count, writeDur, readDur)
+	})
+}
+
+// ResolveAll returns every IPv4 address host resolves to, in resolver order without duplicates. An IPv4
+// literal is returned as is; nil is returned if resolution fails.
+func ResolveAll(host string) []string {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil && !seen[ip4.String()] {
+			seen[ip4.String()] = true
+			addrs = append(addrs, ip4.String())
+		}
+	}
+	return addrs
+}
+
+// newMulti creates a multi-target operation from an instance per target made by create.
+func newMulti(targets []string, create func(target string) *traceroute) *multi {
+	m := &multi{
+		pings: make([]*ping, len(targets)),       // Initialize per-target ping instances.
+		stats: make([]TargetStats, len(targets)), // Initialize per-target statistics.
+		mu:    &sync.Mutex{},                     // Initialize mutex for thread safety.
+	}
+	for i, target := range targets {
+		i, target := i, target
+		m.pings[i] = create(target)
+		m.stats[i] = TargetStats{Target: target, Ip4: m.pings[i].Ip4()}
+		m.pings[i].PongHandler(func(pong *Proto) { m.pong(i, pong) })
+	}
+	return m
+}
+
+// Context sets the context for cancellation on every target.
+func (m *multi) Context(ctx context.Context) {
+	for _, p := range m.pings {
+		p.Context(ctx)
+	}
+}
+
+// PongHandler sets the callback function for handling pong responses, labeled with their target.
+func (m *multi) PongHandler(handler func(target string, pong *Proto)) { m.pongHandler = handler }
+
+// Pings returns the underlying ping instances, one per target, for per-target configuration.
+func (m *multi) Pings() []*ping { return m.pings }
+
+// Run pings all targets concurrently and blocks until every target finishes.
+func (m *multi) Run() {
+	wg := &sync.WaitGroup{}
+	for _, p := range m.pings {
+		wg.Add(1)
+		go func(p *ping) {
+			defer wg.Done()
+			p.Run()
+		}(p)
+	}
+	wg.Wait()
+}
+
+// RunReports runs every target concurrently like Run and returns their reports, in the order given.
+func (m *multi) RunReports() []*Report {
+	reports := make([]*Report, len(m.pings))
+	wg := &sync.WaitGroup{}
+	for i, p := range m.pings {
+		wg.Add(1)
+		go func(i int, p *ping) {
+			defer wg.Done()
+			reports[i] = p.RunReport()
+		}(i, p)
+	}
+	wg.Wait()
+	return reports
+}
+
+// Stop terminates the ping operation of every target.
+func (m *multi) Stop() {
+	for _, p := range m.pings {
+		p.Stop()
+	}
+}
+
+// Stats returns a snapshot of the latest statistics for every target, in the order given.
+func (m *multi) Stats() []TargetStats {
+	m.mu.Lock()         // Lock for thread-safe stats access.
+	defer m.mu.Unlock() // Unlock after stats access.
+	return append([]TargetStats(nil), m.stats...)
+}
+
+// pong updates the statistics of the i-th target and invokes the pong handler.
+func (m *multi) pong(i int, pong *Proto) {
+	m.mu.Lock() // Lock for thread-safe stats access.
+	st := &m.stats[i]
+	if !pong.Unreached && !pong.Dup && !pong.OutOfOrder { // Like Statistics, count every probe once.
+		st.Transmitted++
+		if !pong.IsTimeout() && !pong.IsError() {
+			st.Received++ // Rejected probes count as lost.
+			st.LastRtt = pong.Rtt
+		}
+		st.Loss = float64(st.Transmitted-st.Received) / float64(st.Transmitted) * 100
+	}
+	st.Updated = time.Now()
+	target := st.Target
+	m.mu.Unlock() // Unlock before calling out to the handler.
+	if m.pongHandler != nil {
+		m.pongHandler(target, pong) // Invoke pong handler callback if set.
+	}
+}
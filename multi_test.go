@@ -0,0 +1,59 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultiStatsErrors(t *testing.T) {
+	m := MultiPing([]string{"8.8.8.8"}, 3)
+	m.pong(0, pongProto(0, 1, 0, nil, "8.8.8.8", 20*time.Millisecond))
+	unreachable := pongProto(0, 1, 1, nil, "10.0.0.1", 5*time.Millisecond)
+	unreachable.Unreachable = true
+	m.pong(0, unreachable)
+	m.pong(0, &Proto{Ip4: "8.8.8.8", Unreached: true})
+
+	st := m.Stats()[0]
+	if st.Transmitted != 2 || st.Received != 1 || st.Loss != 50 || st.LastRtt != 20*time.Millisecond {
+		t.Errorf("Stats() = %+v; want 2 transmitted, 1 received, 50%% loss, last 20ms", st)
+	}
+}
+
+func TestMultiStatsDup(t *testing.T) {
+	m := MultiPing([]string{"8.8.8.8"}, 3)
+	m.pong(0, pongProto(0, 1, 0, nil, "8.8.8.8", 20*time.Millisecond))
+	dup := pongProto(0, 1, 0, nil, "8.8.8.8", 30*time.Millisecond)
+	dup.Dup = true
+	m.pong(0, dup)
+	m.pong(0, timeoutProto(0, 1, 1))
+
+	st := m.Stats()[0]
+	if st.Transmitted != 2 || st.Received != 1 || st.Loss != 50 || st.LastRtt != 20*time.Millisecond {
+		t.Errorf("Stats() = %+v; want 2 transmitted, 1 received, 50%% loss, last 20ms", st)
+	}
+}
+
+func TestMultiStatsOutOfOrder(t *testing.T) {
+	m := MultiPing([]string{"8.8.8.8"}, 3)
+	m.pong(0, timeoutProto(0, 1, 0))
+	late := pongProto(0, 1, 0, nil, "8.8.8.8", 900*time.Millisecond)
+	late.OutOfOrder = true
+	m.pong(0, late)
+
+	st := m.Stats()[0]
+	if st.Transmitted != 1 || st.Received != 0 || st.Loss != 100 || st.LastRtt != 0 {
+		t.Errorf("Stats() = %+v; want 1 transmitted, 0 received, 100%% loss, no RTT", st)
+	}
+}
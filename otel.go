@@ -0,0 +1,63 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build icmpkg_otel
+
+package icmpkg
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OtelTracer records every probe as an OpenTelemetry span on tracer, spanning from the probe's send time
+// to its reply or timeout, with the target, TTL, ICMP ID/Seq, replying address, RTT and result as
+// attributes. Spans are children of the span in the operation's Context, if any.
+//
+// It is only available when building with -tags icmpkg_otel, so the OpenTelemetry packages are only
+// compiled into programs that opt in.
+func (tr *traceroute) OtelTracer(tracer trace.Tracer) {
+	tr.ProbeHook(func(pto *Proto) {
+		ctx := tr.ctx
+		if ctx == nil {
+			ctx = context.Background() // Start root spans without a context.
+		}
+		result := "reply"
+		if pto.IsTimeout() {
+			result = "timeout"
+		}
+		_, span := tracer.Start(ctx, "icmpkg.probe",
+			trace.WithTimestamp(pto.Sent),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("icmpkg.target", tr.address),
+				attribute.String("icmpkg.target_ip", tr.ip4),
+				attribute.Bool("icmpkg.traceroute", tr.traceroute),
+				attribute.Int("icmpkg.ttl", pto.TTL),
+				attribute.Int("icmpkg.id", pto.ID),
+				attribute.Int("icmpkg.seq", pto.Seq),
+				attribute.String("icmpkg.result", result),
+			),
+		)
+		if pto.IsTimeout() {
+			span.SetStatus(codes.Error, "timeout") // Mark lost probes as failed.
+		} else {
+			span.SetAttributes(
+				attribute.String("icmpkg.hop_ip", pto.Ip4),
+				attribute.Float64("icmpkg.rtt_ms", float64(pto.Rtt.Microseconds())/1000),
+			)
+		}
+		span.End(trace.WithTimestamp(pto.Time))
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build icmpkg_otel
+
+package icmpkg
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOtelTracer(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	tr := Traceroute("127.0.0.1", 3, 1)
+	tr.OtelTracer(tp.Tracer("icmpkg"))
+
+	sent := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	reply := pongProto(2, 7, 0, nil, "10.0.0.1", 1500*time.Microsecond)
+	reply.Sent, reply.Time = sent, sent.Add(1500*time.Microsecond)
+	tr.handle(reply)
+	tr.handle(timeoutProto(3, 7, 1))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("OtelTracer() ended %d spans; want 2", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name() != "icmpkg.probe" {
+			t.Errorf("span name = %q; want icmpkg.probe", span.Name())
+		}
+	}
+	attrs := func(i int) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range spans[i].Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	got := attrs(0)
+	want := map[attribute.Key]attribute.Value{
+		"icmpkg.target":     attribute.StringValue("127.0.0.1"),
+		"icmpkg.traceroute": attribute.BoolValue(true),
+		"icmpkg.ttl":        attribute.IntValue(2),
+		"icmpkg.id":         attribute.IntValue(7),
+		"icmpkg.seq":        attribute.IntValue(0),
+		"icmpkg.result":     attribute.StringValue("reply"),
+		"icmpkg.hop_ip":     attribute.StringValue("10.0.0.1"),
+		"icmpkg.rtt_ms":     attribute.Float64Value(1.5),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("reply attribute %s = %v; want %v", k, got[k].Emit(), v.Emit())
+		}
+	}
+	if !spans[0].StartTime().Equal(sent) || spans[0].EndTime().Sub(spans[0].StartTime()) != 1500*time.Microsecond {
+		t.Errorf("reply span = %v..%v; want %v lasting 1.5ms", spans[0].StartTime(), spans[0].EndTime(), sent)
+	}
+	if st := spans[0].Status(); st.Code != codes.Unset {
+		t.Errorf("reply status = %v; want Unset", st)
+	}
+
+	got = attrs(1)
+	if got["icmpkg.result"] != attribute.StringValue("timeout") || got["icmpkg.ttl"] != attribute.IntValue(3) {
+		t.Errorf("timeout attributes = %v; want result timeout at ttl 3", spans[1].Attributes())
+	}
+	if _, ok := got["icmpkg.rtt_ms"]; ok {
+		t.Error("timeout span has an icmpkg.rtt_ms attribute; want none")
+	}
+	if st := spans[1].Status(); st.Code != codes.Error || st.Description != "timeout" {
+		t.Errorf("timeout status = %v; want Error timeout", st)
+	}
+}
@@ -12,22 +12,38 @@
 package icmpkg
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	logpkg "log"
 	"net"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // Constants defining the network protocol and listening address for ICMP communication.
 const (
-	listenNetwork = "ip4:icmp" // Specifies the ICMP over IPv4 network protocol.
-	listenAddress = "0.0.0.0"  // Listening address to accept all incoming connections.
+	listenNetwork  = "ip4:icmp"      // Specifies the ICMP over IPv4 network protocol.
+	listenAddress  = "0.0.0.0"       // Listening address to accept all incoming connections.
+	listenNetwork6 = "ip6:ipv6-icmp" // Specifies the ICMPv6 over IPv6 network protocol.
+	listenAddress6 = "::"            // Listening address to accept all incoming IPv6 connections.
+
+	protocolICMP     = 1  // IANA protocol number of ICMP, used to parse ICMPv4 messages.
+	codeFragNeeded   = 4  // Code of a Destination Unreachable message reporting that fragmentation was needed.
+	protocolIPv6ICMP = 58 // IANA protocol number of ICMPv6, used to parse ICMPv6 messages.
+	echoHeaderLen    = 8  // Length of the ICMP Echo header preceding the payload.
+
+	reconnectDelay = time.Millisecond * 100 // Delay between failed reconnect attempts.
+	maxPacketSize  = 65535                  // Size of the largest IP packet, that of the read buffer.
 )
 
 // Global variables controlling debug and trace logging based on environment variables.
@@ -38,40 +54,77 @@ var (
 
 // ttlOpt stores TTL (Time To Live) and timestamp information for a packet.
 type ttlOpt struct {
-	ttl  int   // Time To Live value for the packet.
-	unix int64 // Unix timestamp in milliseconds when the packet was sent.
+	ttl  int       // Time To Live value for the packet.
+	seq  int       // Full sequence number of the packet, before it is truncated to 16 bits on the wire.
+	sent time.Time // Time the packet was sent, keeping the monotonic clock reading for the RTT.
+	size int       // Number of bytes written for the packet.
 }
 
 // packet represents an ICMP packet handler with connection, logging, and synchronization primitives.
 type packet struct {
 	lo         *logpkg.Logger    // Logger instance for debug and trace output.
 	packetConn *icmp.PacketConn  // ICMP packet connection for sending and receiving packets.
+	connMu     *sync.RWMutex     // Mutex guarding packetConn while it is replaced on reconnect.
+	retries    int               // Number of reconnect attempts left after the connection closes unexpectedly.
+	stopping   int32             // Set atomically once stop begins, so closing isn't mistaken for a failure.
 	wc         chan<- *Proto     // Write channel for sending Proto messages.
 	rc         <-chan *Proto     // Read channel for receiving Proto messages.
 	mu         *sync.Mutex       // Mutex for thread-safe access to the TTL map.
 	m          map[string]ttlOpt // Map storing TTL and timestamp for packets, keyed by ID-Seq.
+	answered   map[string]ttlOpt // Recently answered packets, kept to recognize duplicate replies.
+	answers    []string          // Keys of answered in the order they were answered, at most maxAnswered.
+	maxAge     time.Duration     // Age after which unanswered packets are evicted from the TTL map, 0 to keep them.
+	swept      time.Time         // Send time of the packet whose store last evicted unanswered packets.
+	ids        map[int]struct{}  // Set of ICMP IDs allocated by the owning operation.
 	wec, rec   chan struct{}     // Channels for signaling write and read goroutine termination.
+	pcapFile   string            // Optional path of a pcap file recording sent and received packets.
+	pcap       *pcapWriter       // Writer for the pcap file, if enabled.
+	now        func() time.Time  // Clock used for RTT calculation, replaced when replaying a capture.
+	size       int               // Size of the Echo payload sent with each probe.
+	verify     int               // Number of leading payload bytes verified in replies, 0 to disable.
+	bpf        bool              // Whether to filter replies by ICMP ID in the kernel.
+	src        net.IP            // Our source address toward the target, compared with quoted headers to detect NAT; nil disables it.
+	listenAddr string            // Local address the socket is bound to, listenAddress unless a source was chosen.
+	v6         bool              // Whether the socket speaks ICMPv6 to an IPv6 target.
+	data       []byte            // Payload every reply must echo, set with PayloadData; nil accepts any payload.
+	tos        int               // Type-of-Service byte, or IPv6 traffic class, of the probes; 0 keeps the default.
+	df         bool              // Whether IPv4 probes are sent with the Don't Fragment bit set.
+	rxts       bool              // Whether RTTs are measured from kernel receive timestamps where available.
+	rx         time.Time         // Kernel receive time of the message being read, zero if unknown; owned by the read loop.
+	dfConn     *ipv4.PacketConn  // Send-only socket with the Don't Fragment bit set, if df is enabled.
 }
 
-// newPacket creates and initializes a new packet handler instance.
+// newPacket creates and initializes a new packet handler instance; run must be called to start it.
 func newPacket(wc chan<- *Proto, rc <-chan *Proto) *packet {
 	pkt := &packet{
-		wc:  wc,                      // Initialize write channel.
-		rc:  rc,                      // Initialize read channel.
-		mu:  &sync.Mutex{},           // Initialize mutex for thread safety.
-		m:   make(map[string]ttlOpt), // Initialize TTL map.
-		wec: make(chan struct{}, 1),  // Initialize write exit channel with buffer size 1.
-		rec: make(chan struct{}, 1),  // Initialize read exit channel with buffer size 1.
+		wc:     wc,                      // Initialize write channel.
+		rc:     rc,                      // Initialize read channel.
+		mu:     &sync.Mutex{},           // Initialize mutex for thread safety.
+		connMu: &sync.RWMutex{},         // Initialize mutex guarding the connection.
+		m:      make(map[string]ttlOpt), // Initialize TTL map.
+		ids:    make(map[int]struct{}),  // Initialize allocated ID set.
+		wec:    make(chan struct{}, 1),  // Initialize write exit channel with buffer size 1.
+		rec:    make(chan struct{}, 1),  // Initialize read exit channel with buffer size 1.
+		now:    time.Now,                // Use the wall clock by default.
+
+		answered:   make(map[string]ttlOpt), // Initialize answered packet map.
+		listenAddr: listenAddress,           // Listen on all addresses by default.
 	}
 	// Set up logger if debug or trace mode is enabled.
 	if icmpkgDebug() || icmpkgTrace() {
 		pkt.lo = logpkg.New(os.Stdout, fmt.Sprintf("[icmp-packet%0-18s] ", ""), logpkg.LstdFlags)
 	}
-	// Start the packet handler's main loop.
-	pkt.run()
 	return pkt
 }
 
+// label names the operation owning the packet handler in its log prefix, e.g. "ping:8.8.8.8", so the logs
+// of operations sharing a process can be told apart. prefix, such as the operation's tag, precedes it.
+func (p *packet) label(prefix, owner string) {
+	if p.lo != nil {
+		p.lo.SetPrefix(fmt.Sprintf("%s[icmp-packet %-18s] ", prefix, owner))
+	}
+}
+
 // debug logs a debug message if debug mode is enabled.
 func (p *packet) debug(format string, arg ...any) {
 	if icmpkgDebug() {
@@ -87,27 +140,45 @@ func (p *packet) trace(format string, arg ...any) {
 }
 
 // listen sets up the ICMP packet connection to listen on the specified network and address.
-func (p *packet) listen() {
+func (p *packet) listen() error {
 	p.trace("listen() start")     // Log start of listen operation.
 	defer p.trace("listen() end") // Log end of listen operation.
 	var err error
 	// Create an ICMP packet connection.
-	p.packetConn, err = icmp.ListenPacket(listenNetwork, listenAddress)
+	p.packetConn, err = icmp.ListenPacket(p.network(), p.listenAddr)
 	if err != nil {
-		// Panic if listening fails, including error details.
-		panic(fmt.Sprintf("listen() listen on[%s:%s] error:%v", listenNetwork, listenAddress, err))
-		return
+		return fmt.Errorf("listen() listen on[%s:%s] error:%v", p.network(), p.listenAddr, err)
 	}
 	// Log successful listening setup.
-	p.trace("listen() listen on %s:%s", listenNetwork, listenAddress)
+	p.trace("listen() listen on %s:%s", p.network(), p.listenAddr)
+	p.setTOS(p.packetConn)          // Mark the probes, if enabled.
+	p.setRxTimestamps(p.packetConn) // Have replies stamped on arrival, if enabled.
+	if p.df && !p.v6 {
+		if p.dfConn, err = p.listenDF(); err != nil {
+			_ = p.packetConn.Close()
+			return fmt.Errorf("listen() don't fragment on[%s:%s] error:%v", p.network(), p.listenAddr, err)
+		}
+	}
+	if p.pcapFile != "" {
+		// Create the pcap file recording sent and received packets.
+		if p.pcap, err = newPcapWriter(p.pcapFile); err != nil {
+			_ = p.packetConn.Close()
+			return fmt.Errorf("listen() create pcap[%s] error:%v", p.pcapFile, err)
+		}
+		p.trace("listen() pcap to %s", p.pcapFile)
+	}
+	return nil
 }
 
-// run initializes the packet handler by setting up the listener and starting read/write goroutines.
+// run initializes the packet handler by setting up the listener and starting read/write goroutines. It
+// panics if the listener can't be set up.
 func (p *packet) run() {
 	p.trace("run() start")     // Log start of run operation.
 	defer p.trace("run() end") // Log end of run operation.
-	p.listen()                 // Set up ICMP listener.
-	p.start()                  // Start read and write goroutines.
+	if err := p.listen(); err != nil {
+		panic(err.Error()) // Set up ICMP listener.
+	}
+	p.start() // Start read and write goroutines.
 }
 
 // start launches separate goroutines for reading and writing ICMP packets.
@@ -120,14 +191,21 @@ func (p *packet) start() {
 
 // stop terminates the read and write goroutines and closes the packet connection.
 func (p *packet) stop() {
-	p.trace("stop() start")     // Log start of stop operation.
-	defer p.trace("stop() end") // Log end of stop operation.
-	p.wec <- struct{}{}         // Signal write goroutine to exit.
-	close(p.wec)                // Close write exit channel.
-	p.rec <- struct{}{}         // Signal read goroutine to exit.
-	close(p.rec)                // Close read exit channel.
-	if p.packetConn != nil {
-		_ = p.packetConn.Close() // Close the ICMP packet connection.
+	p.trace("stop() start")           // Log start of stop operation.
+	defer p.trace("stop() end")       // Log end of stop operation.
+	atomic.StoreInt32(&p.stopping, 1) // Mark the connection as closing deliberately.
+	p.wec <- struct{}{}               // Signal write goroutine to exit.
+	close(p.wec)                      // Close write exit channel.
+	p.rec <- struct{}{}               // Signal read goroutine to exit.
+	close(p.rec)                      // Close read exit channel.
+	if conn := p.conn(); conn != nil {
+		_ = conn.Close() // Close the ICMP packet connection.
+	}
+	if p.dfConn != nil {
+		_ = p.dfConn.Close() // Close the Don't Fragment socket.
+	}
+	if p.pcap != nil {
+		_ = p.pcap.close() // Close the pcap file.
 	}
 }
 
@@ -146,22 +224,32 @@ func (p *packet) startWrite() {
 			setTtl := pto.TTL > 0 // Check if TTL needs to be set.
 			if setTtl {
 				// Set TTL for the packet connection.
-				if err := p.packetConn.IPv4PacketConn().SetTTL(pto.TTL); p.closed(err) {
+				if err := p.setHopLimit(pto.TTL); p.closed(err) {
+					if p.recoverable() {
+						continue // Drop the probe; the read goroutine is reconnecting.
+					}
 					return // Exit if connection is closed.
 				}
 			}
 			// Write packet data to the destination address.
-			_, err := p.packetConn.WriteTo(pto.buf(), pto.Addr)
+			buf := pto.buf()
+			sent := p.now() // Kernel receive timestamps may precede the return of the write.
+			n, err := p.writeTo(buf, pto.Addr)
 			if err != nil {
 				// Log error if write fails.
 				p.debug("conn<<<<<<-err: %s, %v", pto, err)
-				if p.closed(err) {
+				if p.closed(err) && !p.recoverable() {
 					return // Exit if connection is closed.
 				}
 			} else {
 				// Log successful write and store TTL information.
 				p.debug("conn<<<<<<-ok: %s", pto)
-				p.setTTL(pto.TTL, pto.ID, pto.Seq)
+				if p.rxts {
+					p.setTTLAt(pto.TTL, pto.ID, pto.Seq, n, sent)
+				} else {
+					p.setTTL(pto.TTL, pto.ID, pto.Seq, n)
+				}
+				p.capture(nil, addrIP(pto.Addr), pto.TTL, buf) // Record the sent packet.
 			}
 		}
 	}
@@ -169,9 +257,10 @@ func (p *packet) startWrite() {
 
 // startRead handles reading ICMP packets from the network.
 func (p *packet) startRead() {
-	p.trace("startRead() start")     // Log start of read operation.
-	defer p.trace("startRead() end") // Log end of read operation.
-	buf := make([]byte, 64)          // Buffer for reading ICMP packets.
+	p.trace("startRead() start")      // Log start of read operation.
+	defer p.trace("startRead() end")  // Log end of read operation.
+	buf := make([]byte, p.readSize()) // Buffer for reading ICMP packets.
+	oob := make([]byte, 128)          // Buffer for the control messages carrying receive timestamps.
 	for {
 		select {
 		case <-p.rec:
@@ -180,24 +269,35 @@ func (p *packet) startRead() {
 			return
 		default:
 			// Set a read deadline to prevent blocking indefinitely.
-			if err := p.packetConn.SetReadDeadline(time.Now().Add(time.Millisecond * 10)); p.closed(err) {
+			if err := p.conn().SetReadDeadline(time.Now().Add(time.Millisecond * 10)); p.closed(err) {
+				if p.reconnect() {
+					continue // Resume reading on the new connection.
+				}
 				close(p.wc)                      // Close write channel if connection is closed.
 				p.trace("startRead() closed wc") // Log write channel closure.
 				return
 			}
 			// Read packet data from the connection.
-			n, srcAddr, err := p.packetConn.ReadFrom(buf)
+			n, srcAddr, err := p.readFrom(buf, oob)
 			if p.closed(err) {
+				if p.reconnect() {
+					continue // Resume reading on the new connection.
+				}
 				close(p.wc)                      // Close write channel if connection is closed.
 				p.trace("startRead() closed wc") // Log write channel closure.
 				return
 			}
 			if n > 0 && srcAddr != nil {
-				buf2 := buf[:n] // Slice buffer to actual data size.
+				buf2 := buf[:n]                          // Slice buffer to actual data size.
+				p.capture(addrIP(srcAddr), nil, 0, buf2) // Record the received packet.
 				// Parse received ICMP message.
-				if msg, _ := icmp.ParseMessage(1, buf2); msg != nil {
+				if msg, _ := icmp.ParseMessage(p.protocol(), buf2); msg != nil {
 					// Process the parsed message and send to write channel if valid.
 					if pto := p.messageRead(msg, srcAddr); pto != nil {
+						pto.recvBytes = n // Account for the bytes of the reply.
+						if pto.FragNeeded && pto.MTU == 0 {
+							pto.MTU = nextHopMTU(buf2) // ICMPv4 carries the MTU in the unparsed header.
+						}
 						p.debug("conn->>>>>>ok: %s", pto.String()) // Log successful read.
 						p.wc <- pto                                // Send Proto message to write channel.
 					}
@@ -210,67 +310,465 @@ func (p *packet) startRead() {
 // messageRead processes received ICMP messages and returns a Proto instance if valid.
 func (p *packet) messageRead(msg *icmp.Message, srcAddr net.Addr) (pto *Proto) {
 	// parseEcho processes ICMP Echo Reply messages and constructs a Proto instance.
-	parseEcho := func(ec *icmp.Echo) (pto *Proto) {
+	// quoted is set for an Echo Request quoted by an error message, whose payload may be truncated.
+	parseEcho := func(ec *icmp.Echo, quoted bool) (pto *Proto) {
 		if ec != nil && ec.ID > 0 {
+			if !p.owns(ec.ID) {
+				p.trace("messageRead() dropped foreign id: %d seq: %d", ec.ID, ec.Seq)
+				return // Drop replies to IDs allocated by other operations or tools.
+			}
+			if !p.signed(ec.Data, quoted) {
+				p.debug("messageRead() dropped unsigned payload id: %d seq: %d", ec.ID, ec.Seq)
+				return // Drop stray replies whose ID and seq collide with ours but whose payload doesn't.
+			}
 			// Retrieve TTL and RTT for the echo message.
-			if ttl, rtt := p.getTTL(ec); rtt > 0 {
-				pto = pongProto(ttl, ec.ID, ec.Seq, srcAddr, aip4(srcAddr), rtt) // Create Proto instance.
+			if opt, rtt, dup, ok := p.getTTL(ec); ok {
+				pto = pongProto(opt.ttl, ec.ID, opt.seq, srcAddr, aip4(srcAddr), rtt) // Create Proto instance.
+				pto.Sent, pto.Time = opt.sent, opt.sent.Add(rtt)                      // Record send and receive times.
+				pto.sentBytes = opt.size                                              // Account for the bytes of the probe.
+				pto.IsV6 = p.v6                                                       // Carry the address family.
+				if pto.Dup = dup; dup {
+					p.debug("messageRead() duplicate id: %d seq: %d", ec.ID, ec.Seq)
+					pto.sentBytes = 0 // The probe was accounted for with its first reply.
+				}
+				if !quoted && len(ec.Data) > p.payloadSize(opt) {
+					p.debug("messageRead() padded payload id: %d seq: %d size: %d", ec.ID, ec.Seq, len(ec.Data))
+					pto.Padded = true // A middlebox grew the reply past the payload sent.
+				}
 			}
 		}
 		return
 	}
 
 	switch msg.Type {
-	case ipv4.ICMPTypeEchoReply:
+	case ipv4.ICMPTypeEcho, ipv6.ICMPTypeEchoRequest:
+		// Drop Echo Requests; on loopback the raw socket reads back our own outgoing probes.
+		if ec, ok := msg.Body.(*icmp.Echo); ok && p.pending(ec.ID, ec.Seq) {
+			p.trace("messageRead() dropped own echo id: %d seq: %d", ec.ID, ec.Seq)
+		}
+		return
+
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
 		// Handle ICMP Echo Reply messages.
-		return parseEcho(msg.Body.(*icmp.Echo))
+		ec := msg.Body.(*icmp.Echo)
+		if pto = parseEcho(ec, false); pto != nil && !p.verified(ec) {
+			p.debug("messageRead() corrupt payload id: %d seq: %d", ec.ID, ec.Seq)
+			pto.Corrupt = true // Flag replies whose payload doesn't match what was sent.
+		}
+		return
 
-	case ipv4.ICMPTypeTimeExceeded:
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
 		// Handle ICMP Time Exceeded messages (e.g., TTL expired).
 		ee, ok := msg.Body.(*icmp.TimeExceeded)
 		if !ok {
 			return // Return nil if body is not TimeExceeded.
 		}
-		// Parse the original message embedded in the Time Exceeded message.
-		msg0, _ := icmp.ParseMessage(1, ee.Data[20:])
-		if msg0 == nil {
-			return // Return nil if parsing fails.
+		// Process the Echo message embedded in the Time Exceeded message.
+		if pto = parseEcho(p.quotedEcho(ee.Data), true); pto != nil {
+			p.nat(pto, ee.Data)
+		}
+		return
+
+	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		// Handle Destination Unreachable messages, e.g. from a firewall, including Fragmentation Needed,
+		// sent for probes with DF set that exceed a link's MTU.
+		du, ok := msg.Body.(*icmp.DstUnreach)
+		if !ok {
+			return // Return nil if body is not DstUnreach.
+		}
+		if pto = parseEcho(p.quotedEcho(du.Data), true); pto == nil {
+			return // Return nil if the quoted probe isn't ours.
+		}
+		if msg.Type == ipv4.ICMPTypeDestinationUnreachable && msg.Code == codeFragNeeded {
+			pto.FragNeeded = true // The next-hop MTU is read from the raw message by the caller.
+		} else {
+			pto.Unreachable, pto.UnreachableCode = true, msg.Code
+		}
+		return
+
+	case ipv6.ICMPTypePacketTooBig:
+		// Handle Packet Too Big, the ICMPv6 equivalent of Fragmentation Needed.
+		tb, ok := msg.Body.(*icmp.PacketTooBig)
+		if !ok {
+			return // Return nil if body is not PacketTooBig.
 		}
-		msgBody := msg0.Body
-		if msgBody == nil {
-			return // Return nil if body is missing.
+		if pto = parseEcho(p.quotedEcho(tb.Data), true); pto != nil {
+			pto.FragNeeded, pto.MTU = true, tb.MTU
 		}
-		// Process the embedded Echo message.
-		return parseEcho(msgBody.(*icmp.Echo))
+		return
 	}
 	return // Return nil for unhandled message types.
 }
 
+// quotedEcho parses the Echo Request quoted by an ICMP error message, which starts with the original IP
+// header, or returns nil if the quote is truncated or isn't an Echo Request.
+func (p *packet) quotedEcho(data []byte) *icmp.Echo {
+	// The original packet is quoted from its IP header, whose fixed length depends on the family.
+	hl, proto := ipv4.HeaderLen, protocolICMP
+	if p.v6 {
+		hl, proto = ipv6.HeaderLen, protocolIPv6ICMP
+	}
+	if len(data) < hl {
+		return nil // The quoted header is truncated.
+	}
+	msg, _ := icmp.ParseMessage(proto, data[hl:])
+	if msg == nil {
+		return nil // Parsing failed.
+	}
+	ec, _ := msg.Body.(*icmp.Echo)
+	return ec
+}
+
+// nextHopMTU returns the next-hop MTU carried in bytes 6-7 of a raw ICMPv4 Fragmentation Needed message
+// (RFC 1191), or 0 if it's truncated or the router didn't fill it in.
+func nextHopMTU(b []byte) int {
+	if len(b) < 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b[6:8]))
+}
+
+// nat flags pto as passing a NAT if the source address of the original packet quoted by a Time Exceeded
+// message differs from ours, i.e. a router before the hop rewrote it.
+func (p *packet) nat(pto *Proto, quoted []byte) {
+	var src net.IP
+	if p.v6 {
+		if h, err := ipv6.ParseHeader(quoted); err == nil {
+			src = h.Src
+		}
+	} else if h, err := ipv4.ParseHeader(quoted); err == nil {
+		src = h.Src
+	}
+	if p.src == nil || src == nil || src.Equal(p.src) {
+		return // Nothing to compare, or the source arrived unchanged.
+	}
+	p.debug("messageRead() nat suspected at ttl: %d, quoted src: %s, ours: %s", pto.TTL, src, p.src)
+	pto.NAT, pto.QuotedSrc = true, src.String()
+}
+
+// network returns the network listened on for the packet's address family.
+func (p *packet) network() string {
+	if p.v6 {
+		return listenNetwork6
+	}
+	return listenNetwork
+}
+
+// protocol returns the protocol number used to parse ICMP messages of the packet's address family.
+func (p *packet) protocol() int {
+	if p.v6 {
+		return protocolIPv6ICMP
+	}
+	return protocolICMP
+}
+
+// listenDF opens the socket probes are written to when the Don't Fragment bit is set. As icmp.PacketConn
+// hides its socket, the option is set on a second raw socket that only sends; an ICMP filter blocking every
+// type keeps the replies, which are read on the main socket, from queuing up on it.
+func (p *packet) listenDF() (*ipv4.PacketConn, error) {
+	lc := net.ListenConfig{Control: func(_, _ string, c syscall.RawConn) error { return setDontFragment(c) }}
+	c, err := lc.ListenPacket(context.Background(), p.network(), p.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn := ipv4.NewPacketConn(c)
+	var f ipv4.ICMPFilter
+	f.SetAll(true) // Block every ICMP type.
+	if err := conn.SetICMPFilter(&f); err != nil {
+		p.debug("listenDF() filter err: %v", err) // Log and let the unread replies be dropped.
+	}
+	p.trace("listenDF() listen on %s:%s", p.network(), p.listenAddr)
+	return conn, nil
+}
+
+// writeTo writes a probe to dst, through the Don't Fragment socket if enabled.
+func (p *packet) writeTo(b []byte, dst net.Addr) (int, error) {
+	if p.dfConn != nil {
+		return p.dfConn.WriteTo(b, nil, dst)
+	}
+	return p.conn().WriteTo(b, dst)
+}
+
+// setHopLimit sets the TTL of outgoing probes, which IPv6 calls the hop limit.
+func (p *packet) setHopLimit(ttl int) error {
+	if p.dfConn != nil {
+		return p.dfConn.SetTTL(ttl) // Probes leave through the Don't Fragment socket.
+	}
+	if p.v6 {
+		return p.conn().IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return p.conn().IPv4PacketConn().SetTTL(ttl)
+}
+
+// setTOS sets the Type-of-Service byte, which IPv6 calls the traffic class, of every probe sent on conn.
+// Failures, e.g. on platforms not supporting the option, are logged and leave the probes unmarked.
+func (p *packet) setTOS(conn *icmp.PacketConn) {
+	if p.tos == 0 || conn == nil {
+		return // Marking disabled or not listening.
+	}
+	var err error
+	if p.v6 {
+		err = conn.IPv6PacketConn().SetTrafficClass(p.tos)
+	} else {
+		err = conn.IPv4PacketConn().SetTOS(p.tos)
+	}
+	if err != nil {
+		p.debug("setTOS() err: %v", err) // Log and send unmarked probes.
+		return
+	}
+	p.trace("setTOS() tos: %#x", p.tos)
+}
+
+// readSize returns the read buffer size, that of the largest IP packet, so replies a middlebox padded
+// beyond the payload sent are read in full rather than truncated.
+func (p *packet) readSize() int { return maxPacketSize }
+
+// verified reports whether the payload echoed in a reply matches the one sent. Only the first verify bytes
+// are compared so large payloads stay cheap to check, while the length catches truncation.
+func (p *packet) verified(ec *icmp.Echo) bool {
+	if p.verify <= 0 {
+		return true // Verification disabled.
+	}
+	if p.data != nil {
+		return bytes.HasPrefix(ec.Data, p.data) // An explicit payload must round-trip unchanged.
+	}
+	if len(ec.Data) < p.size {
+		return false // Truncated payload; padding past it is tolerated, see Padded.
+	}
+	n := p.verify
+	if n > p.size {
+		n = p.size
+	}
+	for i := 0; i < n; i++ {
+		if ec.Data[i] != byte(ec.Seq+i) {
+			return false // Payload differs from the pattern generated by payload.
+		}
+	}
+	return true
+}
+
+// signed reports whether an echoed payload matches the one set with PayloadData, if any. A quoted payload
+// only needs to match as far as it was quoted, a reply may be padded past it. With verification enabled,
+// replies are accepted whatever they echo and checked by verified instead.
+func (p *packet) signed(data []byte, quoted bool) bool {
+	if p.data == nil {
+		return true // Any payload is accepted.
+	}
+	if quoted {
+		return bytes.HasPrefix(p.data, data)
+	}
+	if p.verify > 0 {
+		return true // A differing payload is flagged as corrupt rather than dropped.
+	}
+	return bytes.HasPrefix(data, p.data)
+}
+
+// ttlKey creates the TTL map key of a packet from its ID and sequence number. Only the 16 bits of the
+// sequence number that go on the wire are used, so replies match probes whose seq has wrapped around.
+func ttlKey(id, seq int) string { return fmt.Sprintf("%d-%d", id, seq&0xffff) }
+
 // setTTL stores TTL and timestamp information for a packet in the map.
-func (p *packet) setTTL(ttl, id, seq int) {
-	p.mu.Lock()                        // Lock for thread-safe map access.
-	defer p.mu.Unlock()                // Unlock after map access.
-	k := fmt.Sprintf("%d-%d", id, seq) // Create key from ID and sequence number.
-	now := time.Now().UnixMilli()      // Get current timestamp.
-	p.m[k] = ttlOpt{ttl, now}          // Store TTL and timestamp.
-}
-
-// getTTL retrieves TTL and calculates round-trip time (RTT) for a packet.
-func (p *packet) getTTL(ec *icmp.Echo) (ttl int, rtt time.Duration) {
-	p.mu.Lock()                              // Lock for thread-safe map access.
-	defer p.mu.Unlock()                      // Unlock after map access.
-	k := fmt.Sprintf("%d-%d", ec.ID, ec.Seq) // Create key from ID and sequence number.
-	opt, ok := p.m[k]                        // Retrieve TTL option from map.
+func (p *packet) setTTL(ttl, id, seq, size int) { p.setTTLAt(ttl, id, seq, size, p.now()) }
+
+// setTTLAt stores the TTL option of a packet like setTTL, with sent as its send time.
+func (p *packet) setTTLAt(ttl, id, seq, size int, sent time.Time) {
+	p.mu.Lock()                           // Lock for thread-safe map access.
+	defer p.mu.Unlock()                   // Unlock after map access.
+	k := ttlKey(id, seq)                  // Create key from ID and sequence number.
+	p.m[k] = ttlOpt{ttl, seq, sent, size} // Store TTL, full sequence number, timestamp and size.
+	delete(p.answered, k)                 // A wrapped seq starts afresh; its old replies are no duplicates.
+	if p.maxAge > 0 && sent.Sub(p.swept) >= p.maxAge {
+		p.evict(sent.Add(-p.maxAge)) // Sweep at most once per maxAge, bounding the map by the probes sent meanwhile.
+		p.swept = sent
+	}
+}
+
+// evict removes the packets sent before the given time from the TTL map; they timed out long ago and a
+// reply still arriving for them is dropped like a stray one. The caller must hold p.mu.
+func (p *packet) evict(before time.Time) {
+	for k, opt := range p.m {
+		if opt.sent.Before(before) {
+			delete(p.m, k)
+		}
+	}
+}
+
+// maxAnswered bounds the number of answered packets remembered for detecting duplicate replies; a
+// duplicate arriving after maxAnswered later probes were answered is dropped like a stray reply.
+const maxAnswered = 1024
+
+// getTTL retrieves the stored TTL option and calculates round-trip time (RTT) for a packet at nanosecond
+// resolution, reporting ok if the packet was found. A packet that was already answered is found as a
+// duplicate, with dup set.
+func (p *packet) getTTL(ec *icmp.Echo) (opt ttlOpt, rtt time.Duration, dup, ok bool) {
+	p.mu.Lock()                // Lock for thread-safe map access.
+	defer p.mu.Unlock()        // Unlock after map access.
+	k := ttlKey(ec.ID, ec.Seq) // Create key from ID and sequence number.
+	opt, ok = p.m[k]           // Retrieve TTL option from map.
 	if !ok {
-		return // Return zero values if not found.
+		opt, ok = p.answered[k] // Look for a packet answered before.
+		if !ok {
+			return // Return zero values if not found.
+		}
+		return opt, p.received().Sub(opt.sent), true, true // Return the duplicate with its RTT.
+	}
+	delete(p.m, k) // Remove entry from map.
+	if len(p.answers) >= maxAnswered {
+		delete(p.answered, p.answers[0]) // Forget the oldest answered packet.
+		p.answers = p.answers[1:]
 	}
-	delete(p.m, k)                // Remove entry from map.
-	now := time.Now().UnixMilli() // Get current timestamp.
-	ms := now - opt.unix          // Calculate time difference in milliseconds.
-	if ms == 0 {
-		ms = 1 // Ensure non-zero RTT.
+	p.answered[k] = opt // Remember the packet for its duplicates.
+	p.answers = append(p.answers, k)
+	return opt, p.received().Sub(opt.sent), false, true // Return TTL option and RTT.
+}
+
+// payloadSize returns the size of the payload sent with a probe, taken from the bytes written for it, or
+// the configured size if they weren't recorded.
+func (p *packet) payloadSize(opt ttlOpt) int {
+	if opt.size < echoHeaderLen {
+		return p.size
 	}
-	return opt.ttl, time.Duration(ms) * time.Millisecond // Return TTL and RTT.
+	return opt.size - echoHeaderLen
+}
+
+// received returns the time the message being read arrived: its kernel receive timestamp if it has one,
+// or the current time.
+func (p *packet) received() time.Time {
+	if !p.rx.IsZero() {
+		return p.rx
+	}
+	return p.now()
+}
+
+// ipConn returns the IP socket underlying conn, or nil if it isn't one, e.g. an unprivileged datagram socket.
+func (p *packet) ipConn(conn *icmp.PacketConn) *net.IPConn {
+	var c net.PacketConn
+	if p.v6 {
+		if pc := conn.IPv6PacketConn(); pc != nil {
+			c = pc.PacketConn
+		}
+	} else if pc := conn.IPv4PacketConn(); pc != nil {
+		c = pc.PacketConn
+	}
+	ipc, _ := c.(*net.IPConn)
+	return ipc
+}
+
+// setRxTimestamps enables kernel receive timestamps on conn, if enabled. Where the platform doesn't support
+// them, replies are timed when they are read and the failure is logged when debugging.
+func (p *packet) setRxTimestamps(conn *icmp.PacketConn) {
+	if !p.rxts || conn == nil {
+		return // Timestamps disabled.
+	}
+	ipc := p.ipConn(conn)
+	if ipc == nil {
+		p.debug("setRxTimestamps() err: not an IP socket")
+		return
+	}
+	rc, err := ipc.SyscallConn()
+	if err == nil {
+		err = setRxTimestamps(rc)
+	}
+	if err != nil {
+		p.debug("setRxTimestamps() err: %v", err)
+		return
+	}
+	p.trace("setRxTimestamps() enabled")
+}
+
+// readFrom reads a message from the connection into buf like its ReadFrom, also recording the kernel
+// receive timestamp found in the control messages read into oob in rx, if timestamps are enabled.
+func (p *packet) readFrom(buf, oob []byte) (int, net.Addr, error) {
+	conn := p.conn()
+	p.rx = time.Time{} // Forget the previous message's timestamp.
+	ipc := (*net.IPConn)(nil)
+	if p.rxts {
+		ipc = p.ipConn(conn)
+	}
+	if ipc == nil {
+		return conn.ReadFrom(buf)
+	}
+	n, oobn, _, src, err := ipc.ReadMsgIP(buf, oob)
+	if err != nil || src == nil {
+		return n, nil, err
+	}
+	p.rx, _ = rxTimestamp(oob[:oobn])
+	if !p.v6 && n >= ip4HeaderLen && buf[0]>>4 == 4 {
+		n = copy(buf, buf[int(buf[0]&0x0f)<<2:n]) // Strip the IPv4 header, as ReadFrom does.
+	}
+	return n, src, nil
+}
+
+// conn returns the current ICMP packet connection.
+func (p *packet) conn() *icmp.PacketConn {
+	p.connMu.RLock()         // Lock for thread-safe connection access.
+	defer p.connMu.RUnlock() // Unlock after connection access.
+	return p.packetConn
+}
+
+// recoverable reports whether a closed connection will be re-opened rather than ending the run.
+func (p *packet) recoverable() bool {
+	p.connMu.RLock()         // Lock for thread-safe retry access.
+	defer p.connMu.RUnlock() // Unlock after retry access.
+	return p.retries > 0 && atomic.LoadInt32(&p.stopping) == 0
+}
+
+// reconnect re-opens the ICMP packet connection after it was closed unexpectedly, keeping the TTL map so
+// replies to in-flight probes can still be matched. It gives up once the retry limit is exhausted.
+func (p *packet) reconnect() bool {
+	p.connMu.Lock()         // Lock while the connection is replaced.
+	defer p.connMu.Unlock() // Unlock after the connection is replaced.
+	for p.retries > 0 && atomic.LoadInt32(&p.stopping) == 0 {
+		p.retries--
+		conn, err := icmp.ListenPacket(p.network(), p.listenAddr)
+		if err != nil {
+			p.debug("reconnect() err: %v, retries left: %d", err, p.retries) // Log failed attempt.
+			time.Sleep(reconnectDelay)                                       // Back off before retrying.
+			continue
+		}
+		_ = p.packetConn.Close() // Release the broken connection.
+		p.packetConn = conn
+		p.filter(conn)                                         // Re-attach the socket filter, if enabled.
+		p.setTOS(conn)                                         // Mark the probes again, if enabled.
+		p.setRxTimestamps(conn)                                // Have replies stamped again, if enabled.
+		p.debug("reconnect() ok, retries left: %d", p.retries) // Log successful reconnect.
+		return true
+	}
+	return false
+}
+
+// capture records an ICMP message to the pcap file if enabled.
+func (p *packet) capture(src, dst net.IP, ttl int, msg []byte) {
+	if p.pcap == nil {
+		return // Skip if capturing is disabled.
+	}
+	if err := p.pcap.write(time.Now(), src, dst, ttl, msg); err != nil {
+		p.debug("pcap<<<<<<-err: %v", err) // Log capture failure without interrupting the run.
+	}
+}
+
+// own registers an ICMP ID allocated by the owning operation so replies carrying it are accepted.
+func (p *packet) own(id int) {
+	p.mu.Lock() // Lock for thread-safe set access.
+	p.ids[id] = struct{}{}
+	p.mu.Unlock()      // Unlock after set access.
+	p.filter(p.conn()) // Update the socket filter, if enabled.
+}
+
+// owns reports whether an ICMP ID was allocated by the owning operation.
+func (p *packet) owns(id int) bool {
+	p.mu.Lock()         // Lock for thread-safe set access.
+	defer p.mu.Unlock() // Unlock after set access.
+	_, ok := p.ids[id]
+	return ok
+}
+
+// pending reports whether a packet with the given ID and sequence number is awaiting a reply.
+func (p *packet) pending(id, seq int) bool {
+	p.mu.Lock()         // Lock for thread-safe map access.
+	defer p.mu.Unlock() // Unlock after map access.
+	_, ok := p.m[ttlKey(id, seq)]
+	return ok
 }
 
 // closed checks if an error indicates a closed network connection.
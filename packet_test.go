@@ -0,0 +1,583 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	logpkg "log"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestMessageReadOwnEcho(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.setTTL(0, 7, 1, 0)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	echo := &icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	if pto := pkt.messageRead(echo, src); pto != nil {
+		t.Fatalf("messageRead(own echo) = %s; want nil", pto)
+	}
+	if !pkt.pending(7, 1) {
+		t.Fatal("own echo should leave the probe pending")
+	}
+
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	pto := pkt.messageRead(reply, src)
+	if pto == nil {
+		t.Fatal("messageRead(echo reply) should return non-nil Proto")
+	}
+	if pto.ID != 7 || pto.Seq != 1 || pto.Ip4 != "127.0.0.1" {
+		t.Errorf("pong = %s; want ID 7, Seq 1 from 127.0.0.1", pto)
+	}
+}
+
+func TestMessageReadTimestamps(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	sent := time.UnixMilli(1700000000000)
+	pkt.now = func() time.Time { return sent }
+	pkt.own(7)
+	pkt.setTTL(0, 7, 1, 0)
+
+	recv := sent.Add(15 * time.Millisecond)
+	pkt.now = func() time.Time { return recv }
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	pto := pkt.messageRead(reply, &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+	if pto == nil {
+		t.Fatal("messageRead(echo reply) should return non-nil Proto")
+	}
+	if !pto.Sent.Equal(sent) || !pto.Time.Equal(recv) {
+		t.Errorf("Sent, Time = %v, %v; want %v, %v", pto.Sent, pto.Time, sent, recv)
+	}
+	if pto.Rtt != 15*time.Millisecond {
+		t.Errorf("Rtt = %v; want 15ms", pto.Rtt)
+	}
+}
+
+func TestMessageReadSubMillisecondRtt(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	sent := time.Unix(1700000000, 123456789)
+	pkt.now = func() time.Time { return sent }
+	pkt.own(7)
+	pkt.setTTL(0, 7, 1, 0)
+
+	pkt.now = func() time.Time { return sent.Add(250 * time.Microsecond) }
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	pto := pkt.messageRead(reply, &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+	if pto == nil {
+		t.Fatal("messageRead(echo reply) should return non-nil Proto")
+	}
+	if pto.Rtt != 250*time.Microsecond {
+		t.Errorf("Rtt = %v; want 250µs", pto.Rtt)
+	}
+}
+
+func TestMessageReadUDPAddr(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.setTTL(0, 7, 1, 0)
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	// Unprivileged datagram sockets report the source as a *net.UDPAddr.
+	pto := pkt.messageRead(reply, &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if pto == nil {
+		t.Fatal("messageRead(echo reply) should return non-nil Proto")
+	}
+	if pto.Ip4 != "127.0.0.1" {
+		t.Errorf("Ip4 = %q; want 127.0.0.1", pto.Ip4)
+	}
+}
+
+func TestMessageReadVerify(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.size, pkt.verify = 4096, 16
+	pkt.own(7)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	pkt.setTTL(0, 7, 1, 0)
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: payload(4096, 1)}}
+	if pto := pkt.messageRead(reply, src); pto == nil || pto.Corrupt {
+		t.Fatalf("messageRead(intact reply) = %v; want non-corrupt Proto", pto)
+	}
+
+	corrupt := payload(4096, 2)
+	corrupt[3] ^= 0xff
+	pkt.setTTL(0, 7, 2, 0)
+	reply = &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 2, Data: corrupt}}
+	if pto := pkt.messageRead(reply, src); pto == nil || !pto.Corrupt {
+		t.Fatalf("messageRead(corrupt reply) = %v; want corrupt Proto", pto)
+	}
+
+	pkt.setTTL(0, 7, 3, 0)
+	reply = &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 3, Data: payload(100, 3)}}
+	if pto := pkt.messageRead(reply, src); pto == nil || !pto.Corrupt {
+		t.Fatalf("messageRead(truncated reply) = %v; want corrupt Proto", pto)
+	}
+}
+
+func TestMessageReadVerifySeqStart(t *testing.T) {
+	tr := PingDuration("127.0.0.1", 1, 10*time.Millisecond, 10*time.Millisecond)
+	tr.SeqStart(1000) // Not a multiple of 256, so the pattern must follow the wire seq.
+	tr.PayloadSize(56)
+	tr.VerifyPayload(0)
+	pkt := newPacket(nil, nil)
+	pkt.size, pkt.verify = tr.size, tr.verify
+	pkt.own(7)
+
+	probe := tr.probe(1, 7, 1)
+	pkt.setTTL(1, 7, probe.Seq, 0)
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: probe.Seq, Data: probe.data}}
+	if pto := pkt.messageRead(reply, &net.IPAddr{IP: net.ParseIP("127.0.0.1")}); pto == nil || pto.Corrupt {
+		t.Fatalf("messageRead(echo of seq %d) = %v; want non-corrupt Proto", probe.Seq, pto)
+	}
+}
+
+func TestMessageReadPadded(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.size, pkt.verify = 56, 16
+	pkt.own(7)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	pkt.setTTL(0, 7, 1, 64)
+	padded := append(payload(56, 1), make([]byte, 8)...) // Zero padding appended by a middlebox.
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: padded}}
+	if pto := pkt.messageRead(reply, src); pto == nil || pto.Corrupt || !pto.Padded {
+		t.Fatalf("messageRead(padded reply) = %v; want a matched, padded Proto", pto)
+	}
+	pkt.setTTL(0, 7, 2, 64)
+	reply = &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 2, Data: payload(56, 2)}}
+	if pto := pkt.messageRead(reply, src); pto == nil || pto.Padded {
+		t.Fatalf("messageRead(reply) = %v; want an unpadded Proto", pto)
+	}
+
+	// A padded reply still carries the signature set with PayloadData.
+	pkt.data, pkt.size, pkt.verify = []byte("icmpkg-signature"), 16, 0
+	pkt.setTTL(0, 7, 3, 24)
+	reply = &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 3, Data: []byte("icmpkg-signature\x00\x00")}}
+	if pto := pkt.messageRead(reply, src); pto == nil || !pto.Padded {
+		t.Fatalf("messageRead(padded signed reply) = %v; want a padded Proto", pto)
+	}
+	if pkt.readSize() < 8+56+1024 {
+		t.Errorf("readSize() = %d; want room for padded replies", pkt.readSize())
+	}
+}
+
+func TestMessageReadDuplicate(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	reply := func(seq int) *icmp.Message {
+		return &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: seq}}
+	}
+
+	pkt.setTTL(0, 7, 1, 64)
+	if pto := pkt.messageRead(reply(1), src); pto == nil || pto.Dup {
+		t.Fatalf("messageRead(reply) = %v; want a Proto that isn't a duplicate", pto)
+	}
+	pto := pkt.messageRead(reply(1), src)
+	if pto == nil || !pto.Dup || pto.Seq != 1 || pto.sentBytes != 0 {
+		t.Fatalf("messageRead(duplicate) = %v; want a duplicate Proto for seq 1", pto)
+	}
+	if !strings.HasSuffix(pto.PingLine(), " (DUP!)") {
+		t.Errorf("PingLine() = %q; want a (DUP!) mark", pto.PingLine())
+	}
+	if pto := pkt.messageRead(reply(2), src); pto != nil {
+		t.Errorf("messageRead(unsent seq) = %v; want nil", pto)
+	}
+
+	// Only the latest maxAnswered packets are remembered.
+	for seq := 2; seq < 2+maxAnswered; seq++ {
+		pkt.setTTL(0, 7, seq, 64)
+		pkt.messageRead(reply(seq), src)
+	}
+	if len(pkt.m) != 0 || len(pkt.answered) != maxAnswered || len(pkt.answers) != maxAnswered {
+		t.Fatalf("pending, answered = %d, %d; want 0, %d", len(pkt.m), len(pkt.answered), maxAnswered)
+	}
+	if pto := pkt.messageRead(reply(1), src); pto != nil {
+		t.Errorf("messageRead(forgotten duplicate) = %v; want nil", pto)
+	}
+	if pto := pkt.messageRead(reply(2), src); pto == nil || !pto.Dup {
+		t.Errorf("messageRead(duplicate) = %v; want a duplicate Proto", pto)
+	}
+}
+
+func TestPacketEvictsTimeouts(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.maxAge = 20 * time.Millisecond
+	start := time.Now()
+	for seq := 0; seq < 10000; seq++ {
+		pkt.setTTLAt(0, 7, seq, 64, start.Add(time.Duration(seq)*time.Millisecond)) // None is ever answered.
+		if len(pkt.m) > 41 {
+			t.Fatalf("TTL map holds %d probes after %d timeouts; want at most 41", len(pkt.m), seq+1)
+		}
+	}
+	if !pkt.pending(7, 9999) || !pkt.pending(7, 9980) || pkt.pending(7, 9000) {
+		t.Error("eviction should only drop probes older than maxAge")
+	}
+
+	// A reply to a probe that is still kept is matched.
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	pkt.now = func() time.Time { return start.Add(10000 * time.Millisecond) }
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 9990}}
+	if pto := pkt.messageRead(reply, src); pto == nil || pto.Rtt != 10*time.Millisecond {
+		t.Errorf("messageRead(reply to kept probe) = %v; want a Proto with RTT 10ms", pto)
+	}
+}
+
+func TestMessageReadPayloadData(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.data = []byte("icmpkg-signature")
+	pkt.own(7)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	pkt.setTTL(0, 7, 1, 0)
+	stray := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: []byte("another-process!")}}
+	if pto := pkt.messageRead(stray, src); pto != nil {
+		t.Fatalf("messageRead(stray payload) = %s; want nil", pto)
+	}
+	if !pkt.pending(7, 1) {
+		t.Fatal("a stray reply should leave the probe pending")
+	}
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: []byte("icmpkg-signature")}}
+	if pto := pkt.messageRead(reply, src); pto == nil || pto.Corrupt {
+		t.Fatalf("messageRead(signed reply) = %v; want a valid Proto", pto)
+	}
+
+	// Routers may quote only the start of the probe's payload.
+	exceeded := func(seq int, data string) *icmp.Message {
+		echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 7, Seq: seq, Data: []byte(data)}}).Marshal(nil)
+		quoted := append(ip4Header(nil, net.ParseIP("8.8.8.8"), 1, len(echo)), echo...)
+		return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}
+	}
+	pkt.setTTL(1, 7, 2, 0)
+	if pto := pkt.messageRead(exceeded(2, "icmpkg"), src); pto == nil {
+		t.Fatal("messageRead(truncated quote) should match the signature prefix")
+	}
+	pkt.setTTL(1, 7, 3, 0)
+	if pto := pkt.messageRead(exceeded(3, "other"), src); pto != nil {
+		t.Fatalf("messageRead(foreign quote) = %s; want nil", pto)
+	}
+}
+
+func TestMessageReadPayloadDataVerify(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.data = []byte("crafted\x00payload")
+	pkt.verify = defaultVerifyDepth
+	pkt.own(7)
+	src := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+
+	pkt.setTTL(0, 7, 1, 0)
+	altered := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: []byte("crafted\x00PAYLOAD")}}
+	if pto := pkt.messageRead(altered, src); pto == nil || !pto.Corrupt {
+		t.Fatalf("messageRead(altered payload) = %v; want a corrupt Proto", pto)
+	}
+	pkt.setTTL(0, 7, 2, 0)
+	intact := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 2, Data: []byte("crafted\x00payload")}}
+	if pto := pkt.messageRead(intact, src); pto == nil || pto.Corrupt {
+		t.Fatalf("messageRead(intact payload) = %v; want a valid Proto", pto)
+	}
+}
+
+func TestMessageReadSeqWraparound(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.setTTL(0, 7, 70000, 0)
+	// The sequence number is truncated to 16 bits on the wire.
+	wire := (&Proto{ID: 7, Seq: 70000}).buf()
+	msg, err := icmp.ParseMessage(1, wire)
+	if err != nil {
+		t.Fatalf("ParseMessage() error: %v", err)
+	}
+	if seq := msg.Body.(*icmp.Echo).Seq; seq != 70000-65536 {
+		t.Fatalf("wire seq = %d; want %d", seq, 70000-65536)
+	}
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: msg.Body}
+	pto := pkt.messageRead(reply, &net.IPAddr{IP: net.ParseIP("127.0.0.1")})
+	if pto == nil {
+		t.Fatal("messageRead(echo reply) should match the wrapped sequence number")
+	}
+	if pto.Seq != 70000 {
+		t.Errorf("Seq = %d; want 70000", pto.Seq)
+	}
+}
+
+func TestMessageReadForeignID(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.setTTL(0, 7, 1, 0)
+	pkt.setTTL(0, 8, 1, 0) // A probe whose ID wasn't allocated by this operation.
+	src := &net.IPAddr{IP: net.ParseIP("8.8.8.8")}
+
+	foreign := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 8, Seq: 1}}
+	if pto := pkt.messageRead(foreign, src); pto != nil {
+		t.Errorf("messageRead(foreign id) = %s; want nil", pto)
+	}
+	own := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	if pto := pkt.messageRead(own, src); pto == nil {
+		t.Error("messageRead(own id) should return non-nil Proto")
+	}
+}
+
+func TestMessageReadTimeExceeded(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.own(9)
+	hop := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	exceeded := func(id, seq int) *icmp.Message {
+		echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: seq}}).Marshal(nil)
+		quoted := append(ip4Header(net.ParseIP("10.0.0.2"), net.ParseIP("8.8.8.8"), 1, len(echo)), echo...)
+		return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}
+	}
+
+	pkt.setTTL(3, 7, 1, 0)
+	pkt.setTTL(4, 7, 2, 0)
+	pkt.setTTL(5, 9, 2, 0)
+	pto := pkt.messageRead(exceeded(7, 2), hop)
+	if pto == nil || pto.TTL != 4 || pto.ID != 7 || pto.Seq != 2 || pto.Ip4 != "192.0.2.1" || pto.IsTimeout() {
+		t.Fatalf("messageRead(time exceeded id 7 seq 2) = %v; want the reply of ttl 4 from 192.0.2.1", pto)
+	}
+	if !pkt.pending(7, 1) || pkt.pending(7, 2) || !pkt.pending(9, 2) {
+		t.Error("messageRead(time exceeded) should only match the quoted probe")
+	}
+	if pto := pkt.messageRead(exceeded(9, 2), hop); pto == nil || pto.TTL != 5 || pto.ID != 9 {
+		t.Errorf("messageRead(time exceeded id 9 seq 2) = %v; want the reply of ttl 5", pto)
+	}
+	if pto := pkt.messageRead(exceeded(7, 3), hop); pto != nil {
+		t.Errorf("messageRead(time exceeded for unsent seq) = %v; want nil", pto)
+	}
+	truncated := &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: make([]byte, 10)}}
+	if pto := pkt.messageRead(truncated, hop); pto != nil {
+		t.Errorf("messageRead(truncated quote) = %v; want nil", pto)
+	}
+}
+
+func TestMessageReadConcurrentOperations(t *testing.T) {
+	// Two operations in one process read each other's replies off their raw sockets.
+	a, b := newPacket(nil, nil), newPacket(nil, nil)
+	a.own(7)
+	b.own(8)
+	a.setTTL(0, 7, 1, 0)
+	b.setTTL(0, 8, 1, 0)
+	src := &net.IPAddr{IP: net.ParseIP("8.8.8.8")}
+
+	toA := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	if pto := b.messageRead(toA, src); pto != nil {
+		t.Errorf("messageRead(reply to the other operation) = %s; want nil", pto)
+	}
+	if pto := a.messageRead(toA, src); pto == nil || pto.ID != 7 {
+		t.Errorf("messageRead(own reply) = %v; want a Proto with ID 7", pto)
+	}
+	if !b.pending(8, 1) {
+		t.Error("a foreign reply should leave the operation's own probe pending")
+	}
+}
+
+func TestMessageReadNAT(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	pkt.src = net.ParseIP("10.0.0.2")
+	hop := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	exceeded := func(src string, seq int) *icmp.Message {
+		echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 7, Seq: seq}}).Marshal(nil)
+		quoted := append(ip4Header(net.ParseIP(src), net.ParseIP("8.8.8.8"), 1, len(echo)), echo...)
+		return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}
+	}
+
+	pkt.setTTL(3, 7, 1, 0)
+	if pto := pkt.messageRead(exceeded("10.0.0.2", 1), hop); pto == nil || pto.NAT {
+		t.Fatalf("messageRead(unchanged source) = %v; want a Proto without NAT", pto)
+	}
+	pkt.setTTL(4, 7, 2, 0)
+	pto := pkt.messageRead(exceeded("203.0.113.9", 2), hop)
+	if pto == nil || !pto.NAT || pto.QuotedSrc != "203.0.113.9" {
+		t.Fatalf("messageRead(rewritten source) = %v; want NAT with quoted source 203.0.113.9", pto)
+	}
+}
+
+func TestMessageReadIPv6(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.v6 = true
+	pkt.own(7)
+	pkt.src = net.ParseIP("2001:db8::2")
+	hop := &net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+
+	pkt.setTTL(0, 7, 1, 0)
+	reply := &icmp.Message{Type: ipv6.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}
+	if pto := pkt.messageRead(reply, hop); pto == nil || !pto.IsV6 || pto.Ip4 != "2001:db8::1" {
+		t.Fatalf("messageRead(ICMPv6 echo reply) = %v; want an IPv6 Proto from 2001:db8::1", pto)
+	}
+
+	echo, _ := (&icmp.Message{Type: ipv6.ICMPTypeEchoRequest, Body: &icmp.Echo{ID: 7, Seq: 2}}).Marshal(nil)
+	quoted := append(ip6Header(net.ParseIP("2001:db8::9"), net.ParseIP("2001:db8::8"), 1, len(echo)), echo...)
+	exceeded := &icmp.Message{Type: ipv6.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}
+	pkt.setTTL(2, 7, 2, 0)
+	pto := pkt.messageRead(exceeded, hop)
+	if pto == nil || pto.TTL != 2 || pto.Seq != 2 {
+		t.Fatalf("messageRead(ICMPv6 time exceeded) = %v; want TTL 2, Seq 2", pto)
+	}
+	if !pto.NAT || pto.QuotedSrc != "2001:db8::9" {
+		t.Errorf("NAT = %v, QuotedSrc = %q; want the rewritten source 2001:db8::9", pto.NAT, pto.QuotedSrc)
+	}
+
+	short := &icmp.Message{Type: ipv6.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted[:20]}}
+	if pto := pkt.messageRead(short, hop); pto != nil {
+		t.Errorf("messageRead(truncated quote) = %s; want nil", pto)
+	}
+}
+
+func TestPacketLabel(t *testing.T) {
+	p := newPacket(nil, nil)
+	p.lo = logpkg.New(nil, "", 0)
+	p.label("[run-1] ", "ping:8.8.8.8")
+	if got := p.lo.Prefix(); !strings.HasPrefix(got, "[run-1] [icmp-packet ping:8.8.8.8") {
+		t.Errorf("label() prefix = %q; want the tag and owning operation", got)
+	}
+}
+
+func TestPacketTOS(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := newPacket(nil, nil)
+	p.tos = 0xb8 // DSCP EF.
+	if err := p.listen(); err != nil {
+		t.Fatalf("listen() error: %v", err)
+	}
+	defer p.conn().Close()
+	if got, err := p.conn().IPv4PacketConn().TOS(); err != nil || got != 0xb8 {
+		t.Errorf("TOS() = %#x, %v; want 0xb8", got, err)
+	}
+}
+
+func TestPacketRxTimestamps(t *testing.T) {
+	skipWithoutRawSocket(t)
+	if runtime.GOOS != "linux" {
+		t.Skip("kernel receive timestamps are only supported on Linux")
+	}
+	p := newPacket(nil, nil)
+	p.rxts = true
+	if err := p.listen(); err != nil {
+		t.Fatalf("listen() error: %v", err)
+	}
+	defer p.conn().Close()
+	dst := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	before := time.Now()
+	if _, err := p.writeTo((&Proto{ID: 7, Seq: 1}).buf(), dst); err != nil {
+		t.Fatalf("writeTo() error: %v", err)
+	}
+	buf, oob := make([]byte, p.readSize()), make([]byte, 128)
+	_ = p.conn().SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, _, err := p.readFrom(buf, oob)
+		if err != nil {
+			t.Fatalf("readFrom() error: %v", err)
+		}
+		msg, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+			continue // Our own Echo Request, read back on loopback.
+		}
+		if p.rx.Before(before) || p.rx.After(time.Now()) {
+			t.Errorf("rx = %v; want the reply's arrival after %v", p.rx, before)
+		}
+		return
+	}
+}
+
+func TestMessageReadFragNeeded(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.own(7)
+	hop := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	echo, _ := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 7, Seq: 1}}).Marshal(nil)
+	quoted := append(ip4Header(nil, net.ParseIP("8.8.8.8"), 64, len(echo)), echo...)
+	raw, _ := (&icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: codeFragNeeded, Body: &icmp.DstUnreach{Data: quoted}}).Marshal(nil)
+	raw[6], raw[7] = 0x05, 0x78 // Next-hop MTU 1400.
+	msg, err := icmp.ParseMessage(protocolICMP, raw)
+	if err != nil {
+		t.Fatalf("ParseMessage() error: %v", err)
+	}
+
+	pkt.setTTL(0, 7, 1, 0)
+	pto := pkt.messageRead(msg, hop)
+	if pto == nil || !pto.FragNeeded || pto.IsTimeout() || pto.Ip4 != "192.0.2.1" {
+		t.Fatalf("messageRead(frag needed) = %v; want a FragNeeded Proto from 192.0.2.1", pto)
+	}
+	if mtu := nextHopMTU(raw); mtu != 1400 {
+		t.Errorf("nextHopMTU() = %d; want 1400", mtu)
+	}
+	if got := (&Proto{Ip4: "192.0.2.1", Seq: 1, FragNeeded: true, MTU: 1400}).PingLine(); got != "From 192.0.2.1 icmp_seq=1 Frag needed and DF set (mtu = 1400)" {
+		t.Errorf("PingLine() = %q", got)
+	}
+
+	// Other unreachable codes are reported as Unreachable.
+	pkt.setTTL(0, 7, 1, 0)
+	msg.Code = 1
+	pto = pkt.messageRead(msg, hop)
+	if pto == nil || !pto.Unreachable || pto.UnreachableCode != 1 || pto.FragNeeded || pto.Ip4 != "192.0.2.1" {
+		t.Fatalf("messageRead(host unreachable) = %v; want an Unreachable Proto with code 1", pto)
+	}
+	if got := pto.PingLine(); got != "From 192.0.2.1 icmp_seq=1 Destination Host Unreachable" {
+		t.Errorf("PingLine() = %q", got)
+	}
+	if got := pto.ErrorMark(); got != "!H" {
+		t.Errorf("ErrorMark() = %q; want !H", got)
+	}
+}
+
+func TestMessageReadUnreachableV6(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.v6 = true
+	pkt.own(7)
+	echo, _ := (&icmp.Message{Type: ipv6.ICMPTypeEchoRequest, Body: &icmp.Echo{ID: 7, Seq: 1}}).Marshal(nil)
+	quoted := append(ip6Header(nil, net.ParseIP("2001:db8::9"), 64, len(echo)), echo...)
+	msg := &icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 1, Body: &icmp.DstUnreach{Data: quoted}}
+	pkt.setTTL(0, 7, 1, 0)
+	pto := pkt.messageRead(msg, &net.IPAddr{IP: net.ParseIP("2001:db8::1")})
+	if pto == nil || !pto.Unreachable || pto.UnreachableCode != 1 {
+		t.Fatalf("messageRead(administratively prohibited) = %v; want Unreachable with code 1", pto)
+	}
+	pto.IsV6 = true // Set by the traceroute's handler.
+	if got := pto.ErrorMark(); got != "!X" {
+		t.Errorf("ErrorMark() = %q; want !X", got)
+	}
+}
+
+func TestMessageReadPacketTooBig(t *testing.T) {
+	pkt := newPacket(nil, nil)
+	pkt.v6 = true
+	pkt.own(7)
+	echo, _ := (&icmp.Message{Type: ipv6.ICMPTypeEchoRequest, Body: &icmp.Echo{ID: 7, Seq: 1}}).Marshal(nil)
+	quoted := append(ip6Header(nil, net.ParseIP("2001:db8::9"), 64, len(echo)), echo...)
+	msg := &icmp.Message{Type: ipv6.ICMPTypePacketTooBig, Body: &icmp.PacketTooBig{MTU: 1280, Data: quoted}}
+	pkt.setTTL(0, 7, 1, 0)
+	pto := pkt.messageRead(msg, &net.IPAddr{IP: net.ParseIP("2001:db8::1")})
+	if pto == nil || !pto.FragNeeded || pto.MTU != 1280 {
+		t.Fatalf("messageRead(packet too big) = %v; want FragNeeded with MTU 1280", pto)
+	}
+}
+
+func TestPacketDontFragment(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 2, time.Second, time.Second)
+	p.DontFragment(true)
+	p.PayloadSize(1000)
+	p.Interval(10 * time.Millisecond)
+	p.Run()
+	if st := p.Stats(); st.Received != 2 {
+		t.Errorf("Stats().Received = %d; want DF probes below the loopback MTU answered", st.Received)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Constants describing the classic libpcap file format.
+const (
+	pcapMagic        = 0xa1b2c3d4 // Magic number for microsecond-resolution captures.
+	pcapMagicNano    = 0xa1b23c4d // Magic number for nanosecond-resolution captures.
+	pcapVersionMajor = 2          // Major version of the pcap file format.
+	pcapVersionMinor = 4          // Minor version of the pcap file format.
+	pcapSnapLen      = 65535      // Maximum captured length of a single packet.
+	pcapLinkTypeEth  = 1          // LINKTYPE_ETHERNET: each packet starts with an Ethernet header.
+	pcapLinkTypeRaw  = 101        // LINKTYPE_RAW: each packet starts with an IP header.
+	pcapLinkTypeIPv4 = 228        // LINKTYPE_IPV4: each packet starts with an IPv4 header.
+	pcapHeaderLen    = 24         // Length of the pcap global header.
+	pcapRecordLen    = 16         // Length of a pcap record header.
+	ip4HeaderLen     = 20         // Length of the synthesized IPv4 header.
+	ip6HeaderLen     = 40         // Length of the synthesized IPv6 header.
+)
+
+// pcapWriter records ICMP messages to a pcap file, synthesizing the IP header stripped by the socket.
+type pcapWriter struct {
+	mu *sync.Mutex // Mutex serializing writes from the read and write goroutines.
+	f  *os.File    // Underlying capture file.
+}
+
+// newPcapWriter creates the capture file at path and writes the pcap global header.
+func newPcapWriter(path string) (*pcapWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, pcapHeaderLen)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], pcapLinkTypeRaw)
+	if _, err = f.Write(hdr); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &pcapWriter{mu: &sync.Mutex{}, f: f}, nil
+}
+
+// write appends a record holding the ICMP message wrapped in an IP header from src to dst, IPv6 if either
+// address is IPv6 and IPv4 otherwise.
+func (w *pcapWriter) write(ts time.Time, src, dst net.IP, ttl int, msg []byte) error {
+	hdr := ip4Header(src, dst, ttl, len(msg))
+	if (src != nil && src.To4() == nil) || (dst != nil && dst.To4() == nil) {
+		hdr = ip6Header(src, dst, ttl, len(msg))
+	}
+	pkt := append(hdr, msg...)
+	rec := make([]byte, pcapRecordLen)
+	binary.LittleEndian.PutUint32(rec[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(pkt)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(pkt)))
+	w.mu.Lock()         // Lock so records from both goroutines don't interleave.
+	defer w.mu.Unlock() // Unlock after the record is written.
+	if _, err := w.f.Write(rec); err != nil {
+		return err
+	}
+	_, err := w.f.Write(pkt)
+	return err
+}
+
+// close closes the capture file.
+func (w *pcapWriter) close() error { return w.f.Close() }
+
+// ip4Header builds a minimal IPv4 header carrying an ICMP payload of the given length.
+func ip4Header(src, dst net.IP, ttl, payloadLen int) []byte {
+	if ttl <= 0 {
+		ttl = 64 // Use a typical default when the TTL wasn't set explicitly.
+	}
+	h := make([]byte, ip4HeaderLen)
+	h[0] = 0x45 // Version 4, header length 5 words.
+	binary.BigEndian.PutUint16(h[2:], uint16(ip4HeaderLen+payloadLen))
+	h[8] = byte(ttl)
+	h[9] = 1 // Protocol ICMP.
+	copy(h[12:16], ip4OrZero(src))
+	copy(h[16:20], ip4OrZero(dst))
+	binary.BigEndian.PutUint16(h[10:], checksum(h))
+	return h
+}
+
+// ip6Header builds a minimal IPv6 header carrying an ICMPv6 payload of the given length.
+func ip6Header(src, dst net.IP, hopLimit, payloadLen int) []byte {
+	if hopLimit <= 0 {
+		hopLimit = 64 // Use a typical default when the hop limit wasn't set explicitly.
+	}
+	h := make([]byte, ip6HeaderLen)
+	h[0] = 0x60 // Version 6, no traffic class or flow label.
+	binary.BigEndian.PutUint16(h[4:], uint16(payloadLen))
+	h[6] = protocolIPv6ICMP // Next header ICMPv6.
+	h[7] = byte(hopLimit)
+	copy(h[8:24], src.To16()) // A nil address stays ::.
+	copy(h[24:40], dst.To16())
+	return h
+}
+
+// ip4OrZero returns the 4-byte form of ip, or 0.0.0.0 if ip isn't an IPv4 address.
+func ip4OrZero(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return net.IPv4zero.To4()
+}
+
+// checksum computes the Internet checksum of b.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// pcapRecord is a single packet read from a pcap file.
+type pcapRecord struct {
+	ts   time.Time // Capture timestamp.
+	data []byte    // Captured bytes, starting at the link-layer header.
+}
+
+// readPcap reads all records of a classic pcap file in either byte order.
+func readPcap(path string) (linkType uint32, records []pcapRecord, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if len(b) < pcapHeaderLen {
+		return 0, nil, fmt.Errorf("readPcap() %s: short header", path)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	nano := false
+	switch binary.LittleEndian.Uint32(b) {
+	case pcapMagic:
+	case pcapMagicNano:
+		nano = true
+	default:
+		order = binary.BigEndian
+		switch binary.BigEndian.Uint32(b) {
+		case pcapMagic:
+		case pcapMagicNano:
+			nano = true
+		default:
+			return 0, nil, fmt.Errorf("readPcap() %s: not a pcap file", path)
+		}
+	}
+	linkType = order.Uint32(b[20:])
+	for off := pcapHeaderLen; off+pcapRecordLen <= len(b); {
+		sec, frac, n := order.Uint32(b[off:]), order.Uint32(b[off+4:]), int(order.Uint32(b[off+8:]))
+		off += pcapRecordLen
+		if off+n > len(b) {
+			return linkType, records, fmt.Errorf("readPcap() %s: truncated record", path)
+		}
+		if !nano {
+			frac *= 1000 // Convert microseconds to nanoseconds.
+		}
+		records = append(records, pcapRecord{ts: time.Unix(int64(sec), int64(frac)), data: b[off : off+n]})
+		off += n
+	}
+	return
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.pcap")
+	w, err := newPcapWriter(path)
+	if err != nil {
+		t.Fatalf("newPcapWriter failed: %v", err)
+	}
+	local, remote := net.ParseIP("10.0.0.2"), net.ParseIP("8.8.8.8")
+	start := time.Unix(1700000000, 0)
+	reply, _ := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1}}).Marshal(nil)
+	stray, _ := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 9, Seq: 1}}).Marshal(nil)
+	_ = w.write(start, local, remote, 64, (&Proto{ID: 7, Seq: 1}).buf())
+	_ = w.write(start.Add(25*time.Millisecond), remote, local, 57, stray)
+	_ = w.write(start.Add(42*time.Millisecond), remote, local, 57, reply)
+	_ = w.close()
+
+	var pongs []*Proto
+	if err = Replay(path, func(pong *Proto) { pongs = append(pongs, pong) }); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(pongs) != 1 {
+		t.Fatalf("got %d pongs; want 1", len(pongs))
+	}
+	if pto := pongs[0]; pto.ID != 7 || pto.Seq != 1 || pto.TTL != 64 || pto.Ip4 != "8.8.8.8" {
+		t.Errorf("pong = %s; want ID 7, Seq 1, TTL 64 from 8.8.8.8", pto)
+	}
+	if rtt := pongs[0].Rtt; rtt != 42*time.Millisecond {
+		t.Errorf("Rtt = %v; want 42ms", rtt)
+	}
+}
+
+func TestReplayIPv6(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay6.pcap")
+	w, err := newPcapWriter(path)
+	if err != nil {
+		t.Fatalf("newPcapWriter failed: %v", err)
+	}
+	local, router, remote := net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::1"), net.ParseIP("2001:4860:4860::8888")
+	start := time.Unix(1700000000, 0)
+	probe := (&Proto{ID: 7, Seq: 1, IsV6: true}).buf()
+	quoted := append(ip6Header(local, remote, 1, len(probe)), probe...)
+	exceeded, _ := (&icmp.Message{Type: ipv6.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}).Marshal(nil)
+	reply, _ := (&icmp.Message{Type: ipv6.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 2}}).Marshal(nil)
+	_ = w.write(start, local, remote, 1, probe)
+	_ = w.write(start.Add(time.Millisecond), local, remote, 64, (&Proto{ID: 7, Seq: 2, IsV6: true}).buf())
+	_ = w.write(start.Add(5*time.Millisecond), router, local, 64, exceeded)
+	_ = w.write(start.Add(31*time.Millisecond), remote, local, 57, reply)
+	_ = w.close()
+
+	var pongs []*Proto
+	if err = Replay(path, func(pong *Proto) { pongs = append(pongs, pong) }); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(pongs) != 2 {
+		t.Fatalf("got %d pongs; want 2", len(pongs))
+	}
+	if pto := pongs[0]; pto.Seq != 1 || pto.TTL != 1 || pto.Ip4 != "2001:db8::1" || pto.Rtt != 5*time.Millisecond || !pto.IsV6 {
+		t.Errorf("pong = %s; want Seq 1, TTL 1 from 2001:db8::1 after 5ms over IPv6", pto)
+	}
+	if pto := pongs[1]; pto.Seq != 2 || pto.TTL != 64 || pto.Ip4 != "2001:4860:4860::8888" || pto.Rtt != 30*time.Millisecond {
+		t.Errorf("pong = %s; want Seq 2, TTL 64 from 2001:4860:4860::8888 after 30ms", pto)
+	}
+}
+
+func TestReplayPayload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.pcap")
+	w, err := newPcapWriter(path)
+	if err != nil {
+		t.Fatalf("newPcapWriter failed: %v", err)
+	}
+	local, remote := net.ParseIP("10.0.0.2"), net.ParseIP("8.8.8.8")
+	start := time.Unix(1700000000, 0)
+	for seq := 1; seq <= 2; seq++ {
+		_ = w.write(start, local, remote, 64, (&Proto{ID: 7, Seq: seq, data: payload(56, seq)}).buf())
+	}
+	reply, _ := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 1, Data: payload(56, 1)}}).Marshal(nil)
+	padded := append(payload(56, 2), make([]byte, 8)...) // Zero padding appended by a middlebox.
+	paddedReply, _ := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 7, Seq: 2, Data: padded}}).Marshal(nil)
+	_ = w.write(start.Add(10*time.Millisecond), remote, local, 57, reply)
+	_ = w.write(start.Add(20*time.Millisecond), remote, local, 57, paddedReply)
+	_ = w.close()
+
+	var pongs []*Proto
+	if err = Replay(path, func(pong *Proto) { pongs = append(pongs, pong) }); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(pongs) != 2 {
+		t.Fatalf("got %d pongs; want 2", len(pongs))
+	}
+	if pto := pongs[0]; pto.Padded || pto.ReplySize() != 8+56 {
+		t.Errorf("reply to seq 1 padded %t, size %d; want an unpadded reply of 64 bytes", pto.Padded, pto.ReplySize())
+	}
+	if pto := pongs[1]; !pto.Padded || pto.ReplySize() != 8+64 {
+		t.Errorf("reply to seq 2 padded %t, size %d; want a padded reply of 72 bytes", pto.Padded, pto.ReplySize())
+	}
+}
@@ -25,14 +25,36 @@ var (
 // ping is an alias for the traceroute type, used for ICMP ping operations.
 type ping = traceroute
 
-// Ping creates a ping instance with default write and read durations of 500ms.
+// Ping creates a ping instance with default write and read durations of 500ms, see SetDefaultWriteDuration
+// and SetDefaultReadDuration. A count <= 0 pings until Stop is called or the Context is cancelled, like
+// ping(8) without -c.
 func Ping(address string, count int) *ping {
 	// Initialize ping with default durations for write and read operations.
-	return PingDuration(address, count, time.Millisecond*500, time.Millisecond*500)
+	writeDur, readDur := defaultDurations()
+	return PingDuration(address, count, writeDur, readDur)
 }
 
-// PingDuration creates a ping instance with specified write and read durations.
+// PingDuration creates a ping instance with specified write and read durations. A count <= 0 pings until
+// stopped.
 func PingDuration(address string, count int, writeDur, readDur time.Duration) *ping {
 	// Initialize a new traceroute instance for ping with the provided address, count, and durations.
 	return newTraceroute(address, 1, count, writeDur, readDur, false)
 }
+
+// PingFor creates a ping instance that keeps pinging at interval until d has elapsed, rather than for a
+// fixed count. Replies are awaited for at most the default read duration (see SetDefaultReadDuration), or
+// interval if shorter.
+func PingFor(address string, d, interval time.Duration) *ping {
+	if interval <= 0 {
+		interval = time.Second // Default to one probe per second like ping(8).
+	}
+	count := int(d/interval) + 1 // Number of probes fitting into the duration, counting the one sent at once.
+	writeDur, readDur := defaultDurations()
+	if interval < readDur {
+		readDur = interval // Don't let a timeout outlast the interval.
+	}
+	p := PingDuration(address, count, writeDur, readDur)
+	p.Interval(interval) // Pace probes at the interval.
+	p.Deadline(d)        // Never run past the duration.
+	return p
+}
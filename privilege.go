@@ -0,0 +1,42 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/icmp"
+)
+
+// ErrNoPrivilege is returned by CanRun when the process isn't allowed to open a raw ICMP socket.
+var ErrNoPrivilege = errors.New("icmpkg: raw ICMP socket not permitted")
+
+// CanRun checks whether the process can open the raw ICMP socket every ping and traceroute needs, so a
+// missing privilege can be reported before Run, which panics in that case. The socket is closed again
+// right away. A permission failure wraps ErrNoPrivilege and explains how to grant the privilege; any other
+// failure is returned as is.
+func CanRun() error {
+	conn, err := icmp.ListenPacket(listenNetwork, listenAddress)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			exe, _ := os.Executable()
+			if exe == "" {
+				exe = "<binary>"
+			}
+			return fmt.Errorf("%w: run as root or grant CAP_NET_RAW with `sudo setcap cap_net_raw+ep %s` (%v)", ErrNoPrivilege, exe, err)
+		}
+		return fmt.Errorf("icmpkg: listen on %s:%s: %w", listenNetwork, listenAddress, err)
+	}
+	return conn.Close()
+}
@@ -14,22 +14,54 @@
 package icmpkg
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"reflect"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // Proto represents an ICMP packet's metadata, including TTL, identifiers, and timing information.
+// Every finished probe is reported in a Proto of its own, which the package never reuses or modifies once
+// it is handed to the probe hooks and pong handler, so handlers may retain it. The hooks, the pong handler
+// and a Report share that Proto, though; use Clone for a copy unaffected by changes other handlers make.
 type Proto struct {
-	TTL  int           // Time To Live value for the packet.
-	ID   int           // Identifier for the ICMP packet.
-	Seq  int           // Sequence number for the ICMP packet.
-	Addr net.Addr      // Network address of the destination or source.
-	Ip4  string        // IPv4 address as a string.
-	Rtt  time.Duration // Round-trip time for the packet.
+	TTL             int           // Time To Live value for the packet.
+	ID              int           // Identifier for the ICMP packet.
+	Seq             int           // Sequence number for the ICMP packet.
+	Addr            net.Addr      // Network address of the destination or source.
+	Ip4             string        // IPv4 address as a string, or the IPv6 address if IsV6 is set.
+	Rtt             time.Duration // Round-trip time for the packet.
+	Sent            time.Time     // Time the probe was sent.
+	Time            time.Time     // Time the reply was received, or the probe timed out.
+	Corrupt         bool          // Whether the echoed payload failed verification.
+	Geo             bool          // Whether Lat and Lon were set by the GeoLookup hook.
+	Lat             float64       // Latitude of the replying hop, if Geo is set.
+	Lon             float64       // Longitude of the replying hop, if Geo is set.
+	Private         bool          // Whether the replying hop is a private or bogon address, see IsBogon.
+	Unreached       bool          // Whether this is the final event of a traceroute that never reached the destination.
+	Tag             string        // Run ID set with the Tag option, for correlating output.
+	NAT             bool          // Whether the hop quoted our probe with a rewritten source address, suggesting a NAT before it.
+	QuotedSrc       string        // Source address of the probe as quoted by the hop, if NAT is set.
+	IsV6            bool          // Whether the probe was sent over ICMPv6 to an IPv6 target.
+	Late            bool          // Whether the reply arrived after the read timeout, within the LateGrace window.
+	Host            string        // Host name of Ip4 from reverse DNS, set with ResolveNames once it was looked up.
+	FragNeeded      bool          // Whether the hop at Ip4 dropped the probe as too big for its next link, see DontFragment.
+	MTU             int           // MTU of that link as reported by the hop, if FragNeeded is set; 0 if it didn't say.
+	Unreachable     bool          // Whether the hop at Ip4 answered with Destination Unreachable, e.g. a firewall rejecting the probe.
+	UnreachableCode int           // Code of the Destination Unreachable message, e.g. 1 (host unreachable) for ICMPv4.
+	Padded          bool          // Whether the reply echoed more payload than was sent, e.g. padded by a middlebox, see ReplySize.
+	Dup             bool          // Whether the reply duplicates one already received for the probe, ping's "(DUP!)".
+	OutOfOrder      bool          // Whether the reply arrived after a later probe was handled, its own probe having timed out.
+
+	timeout   bool   // Whether the Proto reports a timeout rather than a reply.
+	data      []byte // Payload carried by an Echo Request.
+	sentBytes int    // Number of bytes written for the probe.
+	recvBytes int    // Number of bytes read for the reply; 0 for a timeout.
 }
 
 // pingProto creates a Proto instance for an ICMP Echo Request (ping).
@@ -47,23 +79,155 @@ func pongProto(ttl, id, seq int, addr net.Addr, ip4 string, rtt time.Duration) *
 // timeoutProto creates a Proto instance for an ICMP timeout event (e.g., TTL exceeded).
 func timeoutProto(ttl, id, seq int) *Proto {
 	// Initialize a Proto instance with the provided TTL, ID, and sequence number, leaving other fields empty.
-	return &Proto{TTL: ttl, ID: id, Seq: seq}
+	return &Proto{TTL: ttl, ID: id, Seq: seq, timeout: true}
+}
+
+// Clone returns a deep copy of the Proto, sharing no memory with it.
+func (p *Proto) Clone() *Proto {
+	q := *p
+	switch a := p.Addr.(type) {
+	case *net.IPAddr:
+		if a != nil {
+			q.Addr = &net.IPAddr{IP: append(net.IP(nil), a.IP...), Zone: a.Zone} // Copy the IP, which is a slice.
+		}
+	case *net.UDPAddr:
+		if a != nil {
+			q.Addr = &net.UDPAddr{IP: append(net.IP(nil), a.IP...), Port: a.Port, Zone: a.Zone}
+		}
+	}
+	if p.data != nil {
+		q.data = append([]byte{}, p.data...) // Copy the payload.
+	}
+	return &q
+}
+
+// Equal reports whether two Protos describe the same probe outcome: the same fields, with times compared
+// as instants and addresses by IP.
+func (p *Proto) Equal(q *Proto) bool {
+	if p == nil || q == nil {
+		return p == q
+	}
+	if !addrIP(p.Addr).Equal(addrIP(q.Addr)) || !p.Sent.Equal(q.Sent) || !p.Time.Equal(q.Time) || !bytes.Equal(p.data, q.data) {
+		return false
+	}
+	a, b := *p, *q
+	a.Addr, a.Sent, a.Time, a.data = nil, time.Time{}, time.Time{}, nil
+	b.Addr, b.Sent, b.Time, b.data = nil, time.Time{}, time.Time{}, nil
+	return reflect.DeepEqual(a, b) // Compare the remaining fields.
+}
+
+// IsError reports whether the probe was answered with an ICMP error, Destination Unreachable or
+// Fragmentation Needed, rather than a reply or Time Exceeded. Statistics count such probes as lost, like
+// ping(8) does, though Ip4 and Rtt tell which hop answered and when.
+func (p *Proto) IsError() bool { return p.Unreachable || p.FragNeeded }
+
+// ErrorMark returns the annotation traceroute(8) prints after a hop answering with an ICMP error, e.g. "!H"
+// for host unreachable, "!X" for administratively prohibited or "!F-1400" for fragmentation needed with
+// the MTU, or nothing if the probe wasn't answered with an error or the code needs no annotation.
+func (p *Proto) ErrorMark() string {
+	if p.FragNeeded {
+		return fmt.Sprintf("!F-%d", p.MTU)
+	}
+	if !p.Unreachable {
+		return ""
+	}
+	marks := map[int]string{0: "!N", 1: "!H", 2: "!P", 3: "", 4: "!F", 5: "!S", 9: "!X", 10: "!X", 13: "!X"}
+	if p.IsV6 {
+		marks = map[int]string{0: "!N", 1: "!X", 3: "!H", 4: ""}
+	}
+	if mark, ok := marks[p.UnreachableCode]; ok {
+		return mark
+	}
+	return fmt.Sprintf("!<%d>", p.UnreachableCode)
+}
+
+// unreachableText describes the code of a Destination Unreachable message the way ping(8) does.
+func unreachableText(v6 bool, code int) string {
+	texts := map[int]string{0: "Destination Net Unreachable", 1: "Destination Host Unreachable", 2: "Destination Protocol Unreachable",
+		3: "Destination Port Unreachable", 9: "Destination Net Prohibited", 10: "Destination Host Prohibited", 13: "Packet filtered"}
+	if v6 {
+		texts = map[int]string{0: "Destination unreachable: No route", 1: "Destination unreachable: Administratively prohibited",
+			3: "Destination unreachable: Address unreachable", 4: "Destination unreachable: Port unreachable"}
+	}
+	if text, ok := texts[code]; ok {
+		return text
+	}
+	return fmt.Sprintf("Destination unreachable, code %d", code)
+}
+
+// IsTimeout reports whether the Proto reports a timeout rather than a reply. Unlike checking Rtt == 0,
+// it doesn't misclassify a genuine near-zero RTT reply as a timeout.
+func (p *Proto) IsTimeout() bool { return p.timeout }
+
+// ReplySize returns the number of ICMP bytes read for the reply, header included, larger than the probe
+// if the reply was Padded, or 0 for a timeout.
+func (p *Proto) ReplySize() int { return p.recvBytes }
+
+// payload generates the Echo payload of size bytes for a sequence number. The pattern depends on seq so
+// a reply echoing another probe's payload is caught by verification as well.
+func payload(size, seq int) []byte {
+	if size <= 0 {
+		return nil // No payload by default.
+	}
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(seq + i) // Fill with a rolling pattern offset by seq.
+	}
+	return data
 }
 
 // String returns a string representation of the Proto instance for logging or debugging.
 func (p *Proto) String() string {
 	// Format the Proto fields into a human-readable string.
-	return fmt.Sprintf("TTL: %d, ID: %d, Seq: %d, Addr: %v, Ip4: %v, Rtt: %v", p.TTL, p.ID, p.Seq, p.Addr, p.Ip4, p.Rtt)
+	return fmt.Sprintf("{TTL: %d, ID: %d, Seq: %d, Addr: %v, Ip4: %v, Rtt: %v}", p.TTL, p.ID, p.Seq, p.Addr, p.Ip4, p.Rtt)
+}
+
+// PingLine renders the Proto as a line of system ping output, "64 bytes from 8.8.8.8: icmp_id=1
+// icmp_seq=0 time=12 ms" for a reply of ReplySize bytes, "Request timeout for icmp_id 1 icmp_seq 0" for
+// a timeout, "From 10.0.0.1 icmp_seq=0 Destination Host Unreachable" for an unreachable target or "From
+// 10.0.0.1 icmp_seq=0 Frag needed and DF set (mtu = 1400)" for a probe too big for the path.
+func (p *Proto) PingLine() string {
+	if p.IsTimeout() {
+		return fmt.Sprintf("Request timeout for icmp_id %d icmp_seq %d", p.ID, p.Seq)
+	}
+	if p.FragNeeded {
+		return fmt.Sprintf("From %s icmp_seq=%d Frag needed and DF set (mtu = %d)", p.Ip4, p.Seq, p.MTU)
+	}
+	if p.Unreachable {
+		return fmt.Sprintf("From %s icmp_seq=%d %s", p.Ip4, p.Seq, unreachableText(p.IsV6, p.UnreachableCode))
+	}
+	line := fmt.Sprintf("%d bytes from %s: icmp_id=%d icmp_seq=%d time=%d ms", p.recvBytes, p.Ip4, p.ID, p.Seq, p.Rtt.Milliseconds())
+	if p.Dup {
+		line += " (DUP!)"
+	}
+	if p.OutOfOrder {
+		line += " (out of order)"
+	}
+	return line
+}
+
+// TracerouteLine renders the Proto as a line of system traceroute output, " 3  192.0.2.1  12 ms" for a
+// reply or " 3  *" for a timeout.
+func (p *Proto) TracerouteLine() string {
+	if p.IsTimeout() {
+		return fmt.Sprintf("%2d  *", p.TTL)
+	}
+	return fmt.Sprintf("%2d  %s  %d ms", p.TTL, p.Ip4, p.Rtt.Milliseconds())
 }
 
 // buf generates the byte representation of an ICMP Echo Request message for the Proto instance.
 func (p *Proto) buf() []byte {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if p.IsV6 {
+		typ = ipv6.ICMPTypeEchoRequest // ICMPv6 has its own type numbers.
+	}
 	// Create an ICMP Echo Request message with the Proto's ID and sequence number.
 	msg := &icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
+		Type: typ,
 		Body: &icmp.Echo{
-			ID:  p.ID,
-			Seq: p.Seq,
+			ID:   p.ID,
+			Seq:  p.Seq,
+			Data: p.data,
 		},
 	}
 	// Marshal the message into a byte slice, ignoring any errors.
@@ -13,12 +13,14 @@
 package icmpkg
 
 import (
+	"bytes"
 	"net"
 	"testing"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 func TestPingProto(t *testing.T) {
@@ -100,6 +102,19 @@ func TestTimeoutProto(t *testing.T) {
 	if pto.Rtt != 0 {
 		t.Errorf("Rtt = %v; want 0", pto.Rtt)
 	}
+	if !pto.IsTimeout() {
+		t.Error("IsTimeout() = false; want true")
+	}
+}
+
+func TestProtoIsTimeout(t *testing.T) {
+	addr := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	if pto := pongProto(64, 1, 1, addr, "127.0.0.1", 0); pto.IsTimeout() {
+		t.Error("zero-RTT reply reported as timeout")
+	}
+	if pto := pingProto(64, 1, 1, addr, "127.0.0.1"); pto.IsTimeout() {
+		t.Error("ping reported as timeout")
+	}
 }
 
 func TestProtoString(t *testing.T) {
@@ -111,6 +126,28 @@ func TestProtoString(t *testing.T) {
 	}
 }
 
+func TestProtoLines(t *testing.T) {
+	pong := pongProto(3, 1, 2, nil, "192.0.2.1", time.Millisecond*12)
+	pong.recvBytes = 8 + 56
+	padded := pongProto(3, 1, 3, nil, "192.0.2.1", time.Millisecond*12)
+	padded.recvBytes = 8 + 18
+	timeout := timeoutProto(3, 1, 2)
+	tests := []struct {
+		got, want string
+	}{
+		{pong.PingLine(), "64 bytes from 192.0.2.1: icmp_id=1 icmp_seq=2 time=12 ms"},
+		{padded.PingLine(), "26 bytes from 192.0.2.1: icmp_id=1 icmp_seq=3 time=12 ms"},
+		{timeout.PingLine(), "Request timeout for icmp_id 1 icmp_seq 2"},
+		{pong.TracerouteLine(), " 3  192.0.2.1  12 ms"},
+		{timeout.TracerouteLine(), " 3  *"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q; want %q", tt.got, tt.want)
+		}
+	}
+}
+
 func TestProtoBuf(t *testing.T) {
 	pto := &Proto{ID: 1, Seq: 1}
 	buf := pto.buf()
@@ -137,3 +174,96 @@ func TestProtoBuf(t *testing.T) {
 		t.Errorf("Seq = %d; want 1", body.Seq)
 	}
 }
+
+func TestProtoBufIPv6(t *testing.T) {
+	msg, err := icmp.ParseMessage(protocolIPv6ICMP, (&Proto{ID: 1, Seq: 1, IsV6: true}).buf())
+	if err != nil {
+		t.Fatalf("buf failed to parse: %v", err)
+	}
+	if msg.Type != ipv6.ICMPTypeEchoRequest {
+		t.Errorf("Message type = %v; want %v", msg.Type, ipv6.ICMPTypeEchoRequest)
+	}
+}
+
+func TestProtoBufPayload(t *testing.T) {
+	for _, size := range []int{1, 56, 1472, 65000} {
+		pto := &Proto{ID: 7, Seq: 3, data: payload(size, 3)}
+		buf := pto.buf()
+		// The ICMP Echo header is 8 bytes: type, code, checksum, ID and sequence number.
+		if want := 8 + size; len(buf) != want {
+			t.Errorf("size %d: len(buf) = %d; want %d", size, len(buf), want)
+		}
+		if !bytes.Equal(buf[8:], pto.data) {
+			t.Errorf("size %d: marshaled bytes don't end with the payload", size)
+		}
+
+		msg, err := icmp.ParseMessage(1, buf)
+		if err != nil {
+			t.Fatalf("size %d: buf failed to parse: %v", size, err)
+		}
+		body, ok := msg.Body.(*icmp.Echo)
+		if !ok {
+			t.Fatalf("size %d: Body is not ICMP Echo", size)
+		}
+		if body.ID != 7 || body.Seq != 3 {
+			t.Errorf("size %d: ID, Seq = %d, %d; want 7, 3", size, body.ID, body.Seq)
+		}
+		if !bytes.Equal(body.Data, pto.data) {
+			t.Errorf("size %d: round-tripped Data differs from the payload", size)
+		}
+	}
+}
+
+func TestPayload(t *testing.T) {
+	if p := payload(0, 1); p != nil {
+		t.Errorf("payload(0, 1) = %v; want nil", p)
+	}
+	if p := payload(4, 1); !bytes.Equal(p, []byte{1, 2, 3, 4}) {
+		t.Errorf("payload(4, 1) = %v; want [1 2 3 4]", p)
+	}
+	if bytes.Equal(payload(64, 1), payload(64, 2)) {
+		t.Error("payloads of different sequence numbers should differ")
+	}
+}
+
+func TestProtoClone(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	p := pongProto(3, 7, 1, &net.IPAddr{IP: net.ParseIP("192.0.2.1")}, "192.0.2.1", time.Millisecond)
+	p.Sent, p.Time, p.data, p.Tag = sent, sent.Add(time.Millisecond), []byte{1, 2, 3}, "run-1"
+	q := p.Clone()
+	if q == p || !q.Equal(p) {
+		t.Fatalf("Clone() = %s; want an equal, distinct Proto", q)
+	}
+	q.Addr.(*net.IPAddr).IP[15] = 9
+	q.data[0] = 9
+	if p.Ip4 != "192.0.2.1" || !addrIP(p.Addr).Equal(net.ParseIP("192.0.2.1")) || p.data[0] != 1 {
+		t.Errorf("changing the clone altered the original: %s, data %v", p, p.data)
+	}
+	if q.Equal(p) {
+		t.Error("Equal() should tell apart Protos whose addresses differ")
+	}
+	udp := &Proto{Addr: &net.UDPAddr{IP: net.ParseIP("192.0.2.1")}}
+	if _, ok := udp.Clone().Addr.(*net.UDPAddr); !ok {
+		t.Error("Clone() should keep the address type")
+	}
+}
+
+func TestProtoEqual(t *testing.T) {
+	sent := time.Unix(1700000000, 0)
+	a := &Proto{TTL: 1, ID: 7, Seq: 2, Ip4: "192.0.2.1", Sent: sent}
+	b := &Proto{TTL: 1, ID: 7, Seq: 2, Ip4: "192.0.2.1", Sent: sent.In(time.UTC)}
+	if !a.Equal(b) {
+		t.Error("Equal() should compare times as instants")
+	}
+	b.Rtt = time.Millisecond
+	if a.Equal(b) {
+		t.Error("Equal() should tell apart Protos whose RTTs differ")
+	}
+	if !timeoutProto(1, 7, 2).Equal(timeoutProto(1, 7, 2)) || timeoutProto(1, 7, 2).Equal(&Proto{TTL: 1, ID: 7, Seq: 2}) {
+		t.Error("Equal() should compare the timeout flag")
+	}
+	var none *Proto
+	if !none.Equal(nil) || a.Equal(nil) {
+		t.Error("Equal() should only consider nil equal to nil")
+	}
+}
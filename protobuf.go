@@ -0,0 +1,169 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+)
+
+// Field numbers of the Probe message, see icmpkg.proto.
+const (
+	pbTTL             = 1
+	pbID              = 2
+	pbSeq             = 3
+	pbIp4             = 4
+	pbRtt             = 5
+	pbSent            = 6
+	pbTime            = 7
+	pbTimeout         = 8
+	pbCorrupt         = 9
+	pbPrivate         = 10
+	pbUnreached       = 11
+	pbGeo             = 12
+	pbLat             = 13
+	pbLon             = 14
+	pbTarget          = 15
+	pbTag             = 16
+	pbNAT             = 17
+	pbQuotedSrc       = 18
+	pbIsV6            = 19
+	pbLate            = 20
+	pbHost            = 21
+	pbFragNeeded      = 22
+	pbMTU             = 23
+	pbUnreachable     = 24
+	pbUnreachableCode = 25
+	pbPadded          = 26
+	pbDup             = 27
+	pbOutOfOrder      = 28
+)
+
+// MarshalProtobuf encodes the Proto as an icmpkg.Probe protobuf message, see icmpkg.proto in the
+// repository. Zero values are omitted as in proto3, so the encoding needs no protobuf runtime.
+func (p *Proto) MarshalProtobuf() []byte { return p.appendProtobuf(nil, "") }
+
+// appendProtobuf appends the Probe message of the Proto to b, with target set if not empty.
+func (p *Proto) appendProtobuf(b []byte, target string) []byte {
+	b = pbAppendInt(b, pbTTL, int64(p.TTL))
+	b = pbAppendInt(b, pbID, int64(p.ID))
+	b = pbAppendInt(b, pbSeq, int64(p.Seq))
+	b = pbAppendString(b, pbIp4, p.Ip4)
+	b = pbAppendInt(b, pbRtt, int64(p.Rtt))
+	if !p.Sent.IsZero() {
+		b = pbAppendInt(b, pbSent, p.Sent.UnixNano())
+	}
+	if !p.Time.IsZero() {
+		b = pbAppendInt(b, pbTime, p.Time.UnixNano())
+	}
+	b = pbAppendBool(b, pbTimeout, p.timeout)
+	b = pbAppendBool(b, pbCorrupt, p.Corrupt)
+	b = pbAppendBool(b, pbPrivate, p.Private)
+	b = pbAppendBool(b, pbUnreached, p.Unreached)
+	b = pbAppendBool(b, pbGeo, p.Geo)
+	b = pbAppendDouble(b, pbLat, p.Lat)
+	b = pbAppendDouble(b, pbLon, p.Lon)
+	b = pbAppendString(b, pbTag, p.Tag)
+	b = pbAppendBool(b, pbNAT, p.NAT)
+	b = pbAppendString(b, pbQuotedSrc, p.QuotedSrc)
+	b = pbAppendBool(b, pbIsV6, p.IsV6)
+	b = pbAppendBool(b, pbLate, p.Late)
+	b = pbAppendString(b, pbHost, p.Host)
+	b = pbAppendBool(b, pbFragNeeded, p.FragNeeded)
+	b = pbAppendInt(b, pbMTU, int64(p.MTU))
+	b = pbAppendBool(b, pbUnreachable, p.Unreachable)
+	b = pbAppendInt(b, pbUnreachableCode, int64(p.UnreachableCode))
+	b = pbAppendBool(b, pbPadded, p.Padded)
+	b = pbAppendBool(b, pbDup, p.Dup)
+	b = pbAppendBool(b, pbOutOfOrder, p.OutOfOrder)
+	return pbAppendString(b, pbTarget, target)
+}
+
+// WriteProtobuf writes the Proto to w as a Probe message prefixed with its varint length, the framing
+// of Java's writeDelimitedTo and Go's protodelim, so a stream of probes can be read back one by one.
+func WriteProtobuf(w io.Writer, pto *Proto) error { return writeProtobuf(w, pto, "") }
+
+// writeProtobuf writes the length-prefixed Probe message of pto with target set.
+func writeProtobuf(w io.Writer, pto *Proto, target string) error {
+	msg := pto.appendProtobuf(nil, target)
+	b := pbAppendVarint(make([]byte, 0, len(msg)+binary.MaxVarintLen32), uint64(len(msg)))
+	_, err := w.Write(append(b, msg...))
+	return err
+}
+
+// ProtobufOutput streams every finished probe, reply or timeout, to w as a length-prefixed Probe message
+// carrying the target address, see WriteProtobuf. Writing stops at the first error, which is logged
+// when debugging.
+func (tr *traceroute) ProtobufOutput(w io.Writer) {
+	var (
+		mu  sync.Mutex
+		err error
+	)
+	tr.ProbeHook(func(pto *Proto) {
+		mu.Lock()         // Lock for thread-safe writer access.
+		defer mu.Unlock() // Unlock after writer access.
+		if err != nil {
+			return // Skip after a failed write.
+		}
+		if err = writeProtobuf(w, pto, tr.address); err != nil {
+			tr.debug("ProtobufOutput() write error: %v", err)
+		}
+	})
+}
+
+// pbAppendVarint appends v in base 128 varint encoding.
+func pbAppendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// pbAppendTag appends the key of a field with its wire type.
+func pbAppendTag(b []byte, field, wireType int) []byte {
+	return pbAppendVarint(b, uint64(field<<3|wireType))
+}
+
+// pbAppendInt appends a varint field, omitted when zero. Negative values take ten bytes as for int64.
+func pbAppendInt(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return pbAppendVarint(pbAppendTag(b, field, 0), uint64(v))
+}
+
+// pbAppendBool appends a bool field, omitted when false.
+func pbAppendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return append(pbAppendTag(b, field, 0), 1)
+}
+
+// pbAppendDouble appends a fixed 64-bit double field, omitted when zero.
+func pbAppendDouble(b []byte, field int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	return append(pbAppendTag(b, field, 1), buf[:]...)
+}
+
+// pbAppendString appends a length-delimited string field, omitted when empty.
+func pbAppendString(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = pbAppendVarint(pbAppendTag(b, field, 2), uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// pbFields decodes a protobuf message into its last value per field number, varints and fixed64 as
+// uint64 and length-delimited fields as string.
+func pbFields(t *testing.T, msg []byte) map[int]any {
+	t.Helper()
+	fields := make(map[int]any)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		msg = msg[n:]
+		switch field, wireType := int(key>>3), key&7; wireType {
+		case 0:
+			v, n := binary.Uvarint(msg)
+			fields[field], msg = v, msg[n:]
+		case 1:
+			fields[field], msg = binary.LittleEndian.Uint64(msg), msg[8:]
+		case 2:
+			l, n := binary.Uvarint(msg)
+			fields[field], msg = string(msg[n:n+int(l)]), msg[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+	}
+	return fields
+}
+
+func TestMarshalProtobuf(t *testing.T) {
+	sent := time.Unix(1700000000, 123456789)
+	pto := &Proto{TTL: 3, ID: 4321, Seq: 70000, Ip4: "192.0.2.1", Rtt: 1500 * time.Microsecond,
+		Sent: sent, Time: sent.Add(1500 * time.Microsecond), Private: true, Geo: true, Lat: 52.5, Lon: -13.4, Tag: "run-1"}
+	got := pbFields(t, pto.MarshalProtobuf())
+	want := map[int]any{
+		pbTTL:     uint64(3),
+		pbID:      uint64(4321),
+		pbSeq:     uint64(70000),
+		pbIp4:     "192.0.2.1",
+		pbRtt:     uint64(1500000),
+		pbSent:    uint64(sent.UnixNano()),
+		pbTime:    uint64(sent.UnixNano() + 1500000),
+		pbPrivate: uint64(1),
+		pbGeo:     uint64(1),
+		pbLat:     math.Float64bits(52.5),
+		pbLon:     math.Float64bits(-13.4),
+		pbTag:     "run-1",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d fields; want %d: %v", len(got), len(want), got)
+	}
+	for field, v := range want {
+		if got[field] != v {
+			t.Errorf("field %d = %v; want %v", field, got[field], v)
+		}
+	}
+
+	timeout := pbFields(t, timeoutProto(1, 2, 0).MarshalProtobuf())
+	if timeout[pbTimeout] != uint64(1) || timeout[pbIp4] != nil || timeout[pbSeq] != nil {
+		t.Errorf("timeout fields = %v; want timeout set, ip4 and seq omitted", timeout)
+	}
+	if v6 := pbFields(t, (&Proto{Ip4: "2001:db8::1", IsV6: true}).MarshalProtobuf()); v6[pbIsV6] != uint64(1) {
+		t.Errorf("IPv6 fields = %v; want is_v6 set", v6)
+	}
+}
+
+func TestWriteProtobuf(t *testing.T) {
+	var buf bytes.Buffer
+	for seq := 0; seq < 3; seq++ {
+		if err := writeProtobuf(&buf, &Proto{TTL: 1, ID: 9, Seq: seq}, "example.com"); err != nil {
+			t.Fatalf("writeProtobuf() error: %v", err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for seq := 0; seq < 3; seq++ {
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatalf("message %d: reading length: %v", seq, err)
+		}
+		msg := make([]byte, l)
+		if _, err = io.ReadFull(r, msg); err != nil {
+			t.Fatalf("message %d: reading body: %v", seq, err)
+		}
+		fields := pbFields(t, msg)
+		if fields[pbTarget] != "example.com" || (seq > 0 && fields[pbSeq] != uint64(seq)) {
+			t.Errorf("message %d = %v", seq, fields)
+		}
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("trailing data after 3 messages")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lookupTimeout bounds a single reverse DNS lookup.
+const lookupTimeout = 5 * time.Second
+
+// nameCache resolves IPs to host names in the background, caching every result, so hops are looked up
+// once however many probes they answer.
+type nameCache struct {
+	mu     *sync.Mutex                                              // Mutex for thread-safe access to the cache.
+	names  map[string]*string                                       // Host name per IP; nil while the lookup runs, "" if it failed.
+	lookup func(ctx context.Context, addr string) ([]string, error) // Reverse DNS lookup, replaced in tests.
+}
+
+// newNameCache creates an empty name cache using the default resolver.
+func newNameCache() *nameCache {
+	return &nameCache{mu: &sync.Mutex{}, names: make(map[string]*string), lookup: net.DefaultResolver.LookupAddr}
+}
+
+// name returns the host name of ip if it was resolved already. Otherwise it starts resolving it in the
+// background, unless that is under way, and returns "" at once.
+func (c *nameCache) name(ip string) string {
+	c.mu.Lock()         // Lock for thread-safe cache access.
+	defer c.mu.Unlock() // Unlock after cache access.
+	if name, ok := c.names[ip]; ok {
+		if name == nil {
+			return "" // Still resolving.
+		}
+		return *name
+	}
+	c.names[ip] = nil // Mark the lookup as under way.
+	go c.resolve(ip)
+	return ""
+}
+
+// resolve looks up the host name of ip and caches it, or "" if it has none.
+func (c *nameCache) resolve(ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	var name string
+	if names, err := c.lookup(ctx, ip); err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".") // Drop the root label of the FQDN.
+	}
+	c.mu.Lock()         // Lock for thread-safe cache access.
+	defer c.mu.Unlock() // Unlock after cache access.
+	c.names[ip] = &name
+}
+
+// ResolveNames enables reverse DNS lookups of the replying hops, setting Proto.Host to the host name of
+// Proto.Ip4. Lookups run in the background and are cached per IP, so the probes never wait for DNS: the
+// first replies of a hop may come without Host until its lookup finished. Hops without a name keep Host
+// empty.
+func (tr *traceroute) ResolveNames(enabled bool) {
+	tr.names = nil
+	if enabled {
+		tr.names = newNameCache()
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNameCache(t *testing.T) {
+	c := newNameCache()
+	release := make(chan struct{})
+	var lookups int32
+	c.lookup = func(ctx context.Context, addr string) ([]string, error) {
+		atomic.AddInt32(&lookups, 1)
+		<-release // Hold the lookup until the test lets it finish.
+		if addr == "8.8.8.8" {
+			return []string{"dns.google."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	if got := c.name("8.8.8.8"); got != "" {
+		t.Errorf("name() while resolving = %q; want \"\"", got)
+	}
+	if got := c.name("8.8.8.8"); got != "" {
+		t.Errorf("name() while resolving = %q; want \"\"", got)
+	}
+	c.name("10.0.0.1")
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for (c.name("8.8.8.8") == "" || atomic.LoadInt32(&lookups) < 2) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := c.name("8.8.8.8"); got != "dns.google" {
+		t.Errorf("name() = %q; want dns.google", got)
+	}
+	if got := c.name("10.0.0.1"); got != "" {
+		t.Errorf("name() of an IP without PTR record = %q; want \"\"", got)
+	}
+	if got := atomic.LoadInt32(&lookups); got != 2 {
+		t.Errorf("looked up %d times; want once per IP", got)
+	}
+}
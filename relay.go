@@ -0,0 +1,297 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// transport performs the packet I/O of an operation: it sends the probes read from its probe channel and
+// delivers the matched replies on its reply channel, which it closes once it stops. The local packet
+// handler and the relay to a remote agent implement it.
+type transport interface {
+	own(id int) // Registers an ICMP ID allocated by the operation, so replies carrying it are accepted.
+	stop()      // Stops the I/O and releases its connection.
+}
+
+// RelayDialer opens a connection to a remote agent serving ServeRelay, e.g. a TCP or SSH connection.
+type RelayDialer func() (io.ReadWriteCloser, error)
+
+// relayHello is the first message of a relay connection, configuring the agent's packet handler.
+type relayHello struct {
+	Target     string `json:"target"`               // IP of the target every probe is sent to.
+	V6         bool   `json:"v6,omitempty"`         // Whether the target is an IPv6 address.
+	Traceroute bool   `json:"traceroute,omitempty"` // Whether to detect NATs against the agent's source address.
+	Size       int    `json:"size,omitempty"`       // Size of the Echo payload, sizing the agent's read buffer.
+	Verify     int    `json:"verify,omitempty"`     // Number of leading payload bytes verified in replies.
+	Data       []byte `json:"data,omitempty"`       // Payload every reply must echo, if set with PayloadData.
+	BPF        bool   `json:"bpf,omitempty"`        // Whether to filter replies by ICMP ID in the kernel.
+	TOS        int    `json:"tos,omitempty"`        // Type-of-Service byte of the probes.
+	DF         bool   `json:"df,omitempty"`         // Whether to set the Don't Fragment bit on the probes.
+	RxTS       bool   `json:"rxts,omitempty"`       // Whether to time replies with kernel receive timestamps.
+
+	MaxAge time.Duration `json:"max_age,omitempty"` // Age after which unanswered probes are forgotten, 0 to keep them.
+}
+
+// relayProbe is a probe the agent is asked to send.
+type relayProbe struct {
+	TTL  int    `json:"ttl"`            // TTL of the probe.
+	ID   int    `json:"id"`             // ICMP ID of the probe.
+	Seq  int    `json:"seq"`            // Full sequence number of the probe.
+	Data []byte `json:"data,omitempty"` // Echo payload of the probe.
+}
+
+// relayReply is a reply matched by the agent, with its times taken on the agent's clock.
+type relayReply struct {
+	TTL         int           `json:"ttl"`                   // TTL of the answered probe.
+	ID          int           `json:"id"`                    // ICMP ID of the answered probe.
+	Seq         int           `json:"seq"`                   // Full sequence number of the answered probe.
+	Ip4         string        `json:"ip4"`                   // Address of the replying hop.
+	Rtt         time.Duration `json:"rtt"`                   // Round-trip time measured by the agent.
+	Sent        time.Time     `json:"sent"`                  // Time the agent sent the probe.
+	Time        time.Time     `json:"time"`                  // Time the agent received the reply.
+	Corrupt     bool          `json:"corrupt,omitempty"`     // Whether the echoed payload failed verification.
+	NAT         bool          `json:"nat,omitempty"`         // Whether a NAT was detected before the hop.
+	QuotedSrc   string        `json:"quoted_src,omitempty"`  // Source address quoted by the hop, if NAT is set.
+	FragNeeded  bool          `json:"frag_needed,omitempty"` // Whether the hop dropped the probe as too big.
+	MTU         int           `json:"mtu,omitempty"`         // MTU reported by the hop, if FragNeeded is set.
+	Unreachable bool          `json:"unreachable,omitempty"` // Whether the hop answered with Destination Unreachable.
+	Code        int           `json:"code,omitempty"`        // Code of the Destination Unreachable message.
+	Padded      bool          `json:"padded,omitempty"`      // Whether the reply echoed more payload than was sent.
+	Dup         bool          `json:"dup,omitempty"`         // Whether the reply duplicates one already received.
+	SentBytes   int           `json:"sent_bytes"`            // Number of bytes written for the probe.
+	RecvBytes   int           `json:"recv_bytes"`            // Number of bytes read for the reply.
+}
+
+// newRelayReply creates the relay message of a reply matched by the agent's packet handler.
+func newRelayReply(pto *Proto) relayReply {
+	return relayReply{
+		TTL: pto.TTL, ID: pto.ID, Seq: pto.Seq, Ip4: pto.Ip4, Rtt: pto.Rtt, Sent: pto.Sent, Time: pto.Time,
+		Corrupt: pto.Corrupt, NAT: pto.NAT, QuotedSrc: pto.QuotedSrc, SentBytes: pto.sentBytes, RecvBytes: pto.recvBytes,
+		FragNeeded: pto.FragNeeded, MTU: pto.MTU, Unreachable: pto.Unreachable, Code: pto.UnreachableCode,
+		Padded: pto.Padded, Dup: pto.Dup,
+	}
+}
+
+// proto turns a reply received from the agent back into the Proto its packet handler matched.
+func (r relayReply) proto(v6 bool) *Proto {
+	pto := pongProto(r.TTL, r.ID, r.Seq, &net.IPAddr{IP: net.ParseIP(r.Ip4)}, r.Ip4, r.Rtt)
+	pto.Sent, pto.Time = r.Sent, r.Time                                 // Keep the agent's send and receive times.
+	pto.Unreachable, pto.UnreachableCode = r.Unreachable, r.Code        // Keep a rejection of the probe.
+	pto.FragNeeded, pto.MTU = r.FragNeeded, r.MTU                       // Keep a report of a too big probe.
+	pto.Corrupt, pto.NAT, pto.QuotedSrc = r.Corrupt, r.NAT, r.QuotedSrc // Keep the agent's findings.
+	pto.Padded, pto.Dup = r.Padded, r.Dup                               // Keep a padded or duplicate reply.
+	pto.sentBytes, pto.recvBytes = r.SentBytes, r.RecvBytes             // Account for the bytes on the agent's wire.
+	pto.IsV6 = v6                                                       // Carry the address family.
+	return pto
+}
+
+// relayClient is the transport of an operation probing through a remote agent. Probes are forwarded over
+// the connection as JSON messages, and the replies the agent matched are streamed back.
+type relayClient struct {
+	dial    RelayDialer          // Opens the connection to the agent.
+	hello   relayHello           // Configuration sent to the agent first.
+	wc      chan<- *Proto        // Write channel for delivering replies.
+	rc      <-chan *Proto        // Read channel for receiving probes.
+	mu      sync.Mutex           // Mutex guarding conn and stopped.
+	conn    io.ReadWriteCloser   // Connection to the agent, once dialed.
+	stopped bool                 // Whether stop was called.
+	done    chan struct{}        // Closed by stop, waking the goroutines blocked on it.
+	debug   func(string, ...any) // Debug logger of the owning operation.
+}
+
+// newRelayClient creates a relay transport dialing the agent with dial; run must be called to start it.
+func newRelayClient(dial RelayDialer, hello relayHello, wc chan<- *Proto, rc <-chan *Proto, debug func(string, ...any)) *relayClient {
+	return &relayClient{dial: dial, hello: hello, wc: wc, rc: rc, done: make(chan struct{}), debug: debug}
+}
+
+// run dials the agent and starts forwarding in the background, so a slow dial never holds up Stop. If the
+// dial fails the reply channel is closed, which ends the run with StatusError.
+func (c *relayClient) run() { go c.start() }
+
+// start dials the agent, then forwards probes and replies until the connection or the operation ends.
+func (c *relayClient) start() {
+	defer close(c.wc) // Signal the end of the replies, like the packet handler does.
+	conn, err := c.dial()
+	if err != nil {
+		c.debug("relay dial error: %v", err)
+		return
+	}
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		_ = conn.Close() // Stopped while dialing.
+		return
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	go c.startWrite(conn) // Forward probes to the agent.
+	c.startRead(conn)     // Deliver the agent's replies.
+}
+
+// startWrite sends the hello message and then every probe to the agent. A failed write closes the
+// connection, which ends the read goroutine and thus the run.
+func (c *relayClient) startWrite(conn io.ReadWriteCloser) {
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(c.hello); err != nil {
+		c.debug("relay hello error: %v", err)
+		_ = conn.Close()
+		return
+	}
+	for {
+		select {
+		case <-c.done:
+			return // Exit once stopped.
+		case pto, ok := <-c.rc:
+			if !ok {
+				return // Exit if the probe channel is closed.
+			}
+			if err := enc.Encode(relayProbe{TTL: pto.TTL, ID: pto.ID, Seq: pto.Seq, Data: pto.data}); err != nil {
+				c.debug("relay<<<<<<-err: %s, %v", pto, err)
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// startRead delivers the replies streamed back by the agent until the connection ends.
+func (c *relayClient) startRead(conn io.ReadWriteCloser) {
+	dec := json.NewDecoder(conn)
+	for {
+		var r relayReply
+		if err := dec.Decode(&r); err != nil {
+			c.debug("relay->>>>>>err: %v", err)
+			return
+		}
+		select {
+		case c.wc <- r.proto(c.hello.V6):
+		case <-c.done:
+			return // Exit once stopped.
+		}
+	}
+}
+
+// own is a no-op; the agent registers the ID of every probe it is asked to send.
+func (c *relayClient) own(int) {}
+
+// stop ends forwarding and closes the connection to the agent.
+func (c *relayClient) stop() {
+	c.mu.Lock()         // Lock against a concurrent dial.
+	defer c.mu.Unlock() // Unlock once stopped.
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	close(c.done)
+	if c.conn != nil {
+		_ = c.conn.Close() // Unblock the read goroutine.
+	}
+}
+
+// Relay makes the operation probe from a remote vantage point. Instead of opening a local socket, each Run
+// opens a connection with dial to an agent running ServeRelay, which sends the probes and streams the
+// replies back. The target is still resolved locally, and everything above the packet layer, such as the
+// statistics, hooks and reports, works unchanged, with RTTs measured by the agent. Options of the local
+// socket, such as Interface, PcapFile and Reconnect, don't apply. If the connection can't be opened or
+// breaks, the run ends with StatusError. A nil dial restores local probing.
+func (tr *traceroute) Relay(dial RelayDialer) { tr.relay = dial }
+
+// ServeRelay runs the agent side of Relay on conn: it sends the probes it is asked to from this host and
+// streams the replies back, until the operation closes the connection. It returns nil once the connection
+// ended cleanly, and an error if it's malformed or the ICMP socket can't be opened. conn is closed on return.
+func ServeRelay(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	var hello relayHello
+	if err := dec.Decode(&hello); err != nil {
+		return fmt.Errorf("icmpkg: relay hello: %w", err)
+	}
+	target := net.ParseIP(hello.Target)
+	if target == nil {
+		return fmt.Errorf("icmpkg: relay target %q is not an IP", hello.Target)
+	}
+	addr := &net.IPAddr{IP: target}
+	probes, replies := make(chan *Proto, 1), make(chan *Proto, 1)
+	pkt := newPacket(replies, probes)    // Packet handler doing the I/O on behalf of the operation.
+	pkt.size = hello.Size                // Pass the payload size.
+	pkt.data = hello.Data                // Pass the explicit payload replies must echo, if any.
+	pkt.verify = hello.Verify            // Pass the payload verification depth.
+	pkt.bpf = hello.BPF                  // Pass the socket filter option.
+	pkt.df = hello.DF                    // Set the Don't Fragment bit, if enabled.
+	pkt.rxts = hello.RxTS                // Time replies on arrival, if enabled.
+	pkt.maxAge = hello.MaxAge            // Forget probes that timed out long ago.
+	pkt.tos = hello.TOS                  // Mark the probes, if enabled.
+	pkt.v6 = hello.V6                    // Speak ICMPv6 to IPv6 targets.
+	pkt.label("", "relay:"+hello.Target) // Attribute the logs to the relayed operation.
+	if hello.V6 {
+		pkt.listenAddr = listenAddress6 // Listen on all IPv6 addresses.
+	}
+	if hello.Traceroute {
+		pkt.src = sourceIP(addr) // Detect NAT against the agent's source address.
+	}
+	if err := pkt.listen(); err != nil {
+		return fmt.Errorf("icmpkg: relay %w", err)
+	}
+	pkt.start()
+	written := make(chan error, 1) // Receives the result of streaming the replies back.
+	go func() {
+		enc := json.NewEncoder(conn)
+		var err error
+		for pto := range replies {
+			if err != nil {
+				continue // Drain the replies after a failed write so the packet handler can stop.
+			}
+			if err = enc.Encode(newRelayReply(pto)); err != nil {
+				_ = conn.Close() // End the read loop below.
+			}
+		}
+		written <- err
+	}()
+	var rerr, werr error
+	wrote := false // Whether werr was received already.
+loop:
+	for {
+		var p relayProbe
+		if rerr = dec.Decode(&p); rerr != nil {
+			break
+		}
+		pkt.own(p.ID) // Accept the replies to the operation's IDs.
+		pto := pingProto(p.TTL, p.ID, p.Seq, addr, target.String())
+		pto.data, pto.IsV6 = p.Data, hello.V6
+		select {
+		case probes <- pto: // Send the probe.
+		case werr = <-written:
+			wrote = true
+			break loop // The packet handler stopped on its own.
+		}
+	}
+	pkt.stop() // Stop the packet handler, closing the replies.
+	if !wrote {
+		werr = <-written // Wait for the remaining replies to be written.
+	}
+	switch {
+	case errors.Is(rerr, io.EOF):
+		return nil // The operation closed the connection.
+	case werr != nil:
+		return fmt.Errorf("icmpkg: relay write: %w", werr)
+	case rerr == nil:
+		return errors.New("icmpkg: relay socket closed")
+	}
+	return fmt.Errorf("icmpkg: relay read: %w", rerr)
+}
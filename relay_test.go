@@ -0,0 +1,68 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelay(t *testing.T) {
+	skipWithoutRawSocket(t)
+	served := make(chan error, 1)
+	tr := PingDuration("127.0.0.1", 3, time.Second, time.Second)
+	tr.Relay(func() (io.ReadWriteCloser, error) {
+		local, remote := net.Pipe()
+		go func() { served <- ServeRelay(remote) }() // The agent runs in-process for the test.
+		return local, nil
+	})
+	var hosts []string
+	tr.PongHandler(func(pong *Proto) { hosts = append(hosts, pong.Ip4) })
+	tr.Run()
+	if st := tr.Stats(); st.Transmitted != 3 || st.Received != 3 || st.BytesReceived == 0 {
+		t.Errorf("Stats() = %+v; want 3 of 3 replies relayed", st)
+	}
+	for _, host := range hosts {
+		if host != "127.0.0.1" {
+			t.Errorf("relayed reply from %q; want 127.0.0.1", host)
+		}
+	}
+	if err := <-served; err != nil {
+		t.Errorf("ServeRelay() error: %v", err)
+	}
+}
+
+func TestRelayDialError(t *testing.T) {
+	tr := Ping("127.0.0.1", 3)
+	tr.Relay(func() (io.ReadWriteCloser, error) { return nil, errors.New("unreachable agent") })
+	tr.Run()
+	if got := tr.Status(); got != StatusError {
+		t.Errorf("Status() = %v; want %v", got, StatusError)
+	}
+}
+
+func TestServeRelayBadHello(t *testing.T) {
+	local, remote := net.Pipe()
+	go func() {
+		_, _ = local.Write([]byte(`{"target":"not-an-ip"}` + "\n"))
+		_ = local.Close()
+	}()
+	if err := ServeRelay(remote); err == nil || !strings.Contains(err.Error(), "not an IP") {
+		t.Errorf("ServeRelay() error = %v; want an invalid target error", err)
+	}
+}
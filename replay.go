@@ -0,0 +1,109 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Replay feeds the packets recorded in a pcap file through the reply-matching logic without touching
+// the network. Echo Requests found in the capture register probes, and the replies matched to them are
+// passed to handler with RTTs computed from the capture timestamps, making the output deterministic.
+// Captures written by PcapFile are supported as well as raw IP and Ethernet captures, of ICMP over IPv4
+// and of ICMPv6 over IPv6 without extension headers.
+func Replay(path string, handler func(pong *Proto)) error {
+	linkType, records, err := readPcap(path)
+	if err != nil {
+		return err
+	}
+	// Packet handlers used only for matching, one per family; they never listen.
+	pkt4, pkt6 := newPacket(nil, nil), newPacket(nil, nil)
+	pkt6.v6 = true
+	for _, rec := range records {
+		ip, err := replayIP(linkType, rec.data)
+		if err != nil {
+			return err
+		}
+		body, ttl, src, v6, ok := replayICMP(ip)
+		if !ok {
+			continue // Skip anything that isn't ICMP.
+		}
+		pkt := pkt4
+		if v6 {
+			pkt = pkt6
+		}
+		msg, _ := icmp.ParseMessage(pkt.protocol(), body)
+		if msg == nil {
+			continue // Skip unparseable messages.
+		}
+		ts := rec.ts
+		pkt.now = func() time.Time { return ts } // Pin the clock to the capture timestamp.
+		if msg.Type == ipv4.ICMPTypeEcho || msg.Type == ipv6.ICMPTypeEchoRequest {
+			// Register the probe, ignoring the copy of our own request read back on loopback.
+			if ec, ok := msg.Body.(*icmp.Echo); ok && !pkt.pending(ec.ID, ec.Seq) {
+				pkt.own(ec.ID)
+				pkt.setTTL(ttl, ec.ID, ec.Seq, len(body))
+			}
+			continue
+		}
+		if pto := pkt.messageRead(msg, &net.IPAddr{IP: src}); pto != nil && handler != nil {
+			pto.recvBytes = len(body) // Account for the bytes of the reply.
+			handler(pto)              // Deliver the matched reply.
+		}
+	}
+	return nil
+}
+
+// replayICMP returns the ICMP message carried by an IP packet with the packet's TTL, or hop limit, and
+// source address, and whether it is IPv6. ok is false if the packet is malformed or carries no ICMP.
+func replayICMP(ip []byte) (body []byte, ttl int, src net.IP, v6, ok bool) {
+	if len(ip) == 0 {
+		return nil, 0, nil, false, false
+	}
+	switch ip[0] >> 4 {
+	case 4:
+		if len(ip) < ip4HeaderLen || ip[9] != protocolICMP {
+			return nil, 0, nil, false, false
+		}
+		hl := int(ip[0]&0x0f) * 4
+		if hl < ip4HeaderLen || len(ip) < hl {
+			return nil, 0, nil, false, false // Malformed header.
+		}
+		return ip[hl:], int(ip[8]), net.IP(append([]byte(nil), ip[12:16]...)), false, true
+	case 6:
+		if len(ip) < ip6HeaderLen || ip[6] != protocolIPv6ICMP {
+			return nil, 0, nil, true, false // Truncated, or the next header isn't ICMPv6.
+		}
+		return ip[ip6HeaderLen:], int(ip[7]), net.IP(append([]byte(nil), ip[8:24]...)), true, true
+	}
+	return nil, 0, nil, false, false
+}
+
+// replayIP strips the link-layer header of a captured packet and returns the IP packet.
+func replayIP(linkType uint32, data []byte) ([]byte, error) {
+	switch linkType {
+	case pcapLinkTypeRaw, pcapLinkTypeIPv4:
+		return data, nil
+	case pcapLinkTypeEth:
+		if len(data) < 14 || !(data[12] == 0x08 && data[13] == 0x00 || data[12] == 0x86 && data[13] == 0xdd) {
+			return nil, nil // Not an IPv4 or IPv6 frame.
+		}
+		return data[14:], nil
+	}
+	return nil, fmt.Errorf("replayIP() unsupported link type: %d", linkType)
+}
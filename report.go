@@ -0,0 +1,258 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report summarizes a finished ping or traceroute operation.
+type Report struct {
+	Target     string      `json:"target"`        // Target address as given.
+	Tag        string      `json:"tag,omitempty"` // Run ID set with the Tag option.
+	Ip4        string      `json:"ip4"`           // Resolved IPv4 address of the target.
+	Traceroute bool        `json:"traceroute"`    // Whether the operation ran in traceroute mode.
+	Status     RunStatus   `json:"status"`        // How the operation ended.
+	Start      time.Time   `json:"start"`         // Time the operation started.
+	End        time.Time   `json:"end"`           // Time the operation ended.
+	Hops       []HopReport `json:"hops"`          // Results per hop in TTL order; a single entry in ping mode.
+	Statistics Statistics  `json:"statistics"`    // Statistics over all probes.
+
+	mu *sync.Mutex // Mutex for thread-safe access while probes are recorded.
+}
+
+// HopReport holds the probes and statistics of a single hop.
+type HopReport struct {
+	TTL        int        `json:"ttl"`        // TTL of the hop; 0 in ping mode.
+	Probes     []*Proto   `json:"probes"`     // Probes in sequence order, replies and timeouts.
+	Statistics Statistics `json:"statistics"` // Statistics over the hop's probes.
+}
+
+// Statistics summarizes a set of probes.
+type Statistics struct {
+	Transmitted   int           `json:"transmitted"`    // Number of probes sent.
+	Received      int           `json:"received"`       // Number of replies received, including late ones.
+	Late          int           `json:"late"`           // Number of replies that arrived after the read timeout, see LateGrace.
+	Errors        int           `json:"errors"`         // Number of probes answered with an ICMP error, see IsError, counted as lost.
+	Duplicates    int           `json:"duplicates"`     // Number of duplicate replies, see Proto.Dup, not counted as received.
+	OutOfOrder    int           `json:"out_of_order"`   // Number of replies to probes that had timed out, see Proto.OutOfOrder, not counted as received.
+	Loss          float64       `json:"loss"`           // Packet loss percentage.
+	Min           time.Duration `json:"min"`            // Minimum RTT of the replies.
+	Avg           time.Duration `json:"avg"`            // Average RTT of the replies.
+	Max           time.Duration `json:"max"`            // Maximum RTT of the replies.
+	StdDev        time.Duration `json:"stddev"`         // Population standard deviation of the RTTs, ping's mdev.
+	BytesSent     int64         `json:"bytes_sent"`     // Total ICMP bytes of the probes sent.
+	BytesReceived int64         `json:"bytes_received"` // Total ICMP bytes of the replies received.
+}
+
+// RunReport runs the operation like Run and returns a report of every probe together with computed
+// statistics. The pong handler and probe hooks are still invoked while it runs.
+func (tr *traceroute) RunReport() *Report {
+	rep := &Report{Target: tr.address, Tag: tr.tag, Ip4: tr.ip4, Traceroute: tr.traceroute, mu: &sync.Mutex{}}
+	tr.report = rep
+	rep.Start = time.Now()
+	tr.Run()
+	rep.End = time.Now()
+	rep.Status = tr.Status()
+	rep.finish()
+	return rep
+}
+
+// Path returns the address seen at each hop in TTL order, the one answering most probes if several did,
+// or "*" for a hop that never answered. Comparing paths shows where routes to several addresses differ.
+func (r *Report) Path() []string {
+	r.mu.Lock()         // Lock for thread-safe hop access.
+	defer r.mu.Unlock() // Unlock after hop access.
+	path := make([]string, len(r.Hops))
+	for i, h := range r.Hops {
+		seen := make(map[string]int)
+		path[i] = "*"
+		for _, pto := range h.Probes {
+			if pto.IsTimeout() {
+				continue
+			}
+			if seen[pto.Ip4]++; path[i] == "*" || seen[pto.Ip4] > seen[path[i]] {
+				path[i] = pto.Ip4
+			}
+		}
+	}
+	return path
+}
+
+// PathSummary renders the path of a traceroute report on one line for sharing, e.g.
+// "> 10.0.0.1 > * > 8.8.8.8 (reached in 3 hops)", or "(not reached in 30 hops)" if the target never replied.
+func (r *Report) PathSummary() string { return r.summary(r.Path()) }
+
+// PathHop is a hop of a deduplicated path: an address together with the TTLs it answered at.
+type PathHop struct {
+	Addr    string `json:"addr"`     // Address seen at the hop, or "*" if it never answered.
+	TTL     int    `json:"ttl"`      // First TTL the address answered at.
+	LastTTL int    `json:"last_ttl"` // Last TTL the address answered at; TTL unless it repeated.
+}
+
+// String returns the address, noting the TTL range if it answered at several consecutive TTLs, e.g.
+// "10.0.1.1 (ttl 2-3)".
+func (h PathHop) String() string {
+	if h.LastTTL == h.TTL {
+		return h.Addr
+	}
+	return fmt.Sprintf("%s (ttl %d-%d)", h.Addr, h.TTL, h.LastTTL)
+}
+
+// DedupPath returns the path like Path, but collapses a router that answered at consecutive TTLs, as
+// routers that don't decrement the TTL as expected do, into a single hop. Unanswered hops are kept apart.
+func (r *Report) DedupPath() []PathHop {
+	path := r.Path()
+	var hops []PathHop
+	for i, addr := range path {
+		ttl := r.Hops[i].TTL
+		if n := len(hops); n > 0 && addr != "*" && hops[n-1].Addr == addr && hops[n-1].LastTTL == ttl-1 {
+			hops[n-1].LastTTL = ttl // The router answered the next TTL again.
+			continue
+		}
+		hops = append(hops, PathHop{Addr: addr, TTL: ttl, LastTTL: ttl})
+	}
+	return hops
+}
+
+// DedupPathSummary renders the deduplicated path on one line like PathSummary, e.g.
+// "> 10.0.0.1 > 10.0.1.1 (ttl 2-3) > 8.8.8.8 (reached in 4 hops)".
+func (r *Report) DedupPathSummary() string {
+	var path []string
+	for _, h := range r.DedupPath() {
+		path = append(path, h.String())
+	}
+	return r.summary(path)
+}
+
+// summary renders hops on one line and tells whether the target was reached, going by the last hop.
+func (r *Report) summary(path []string) string {
+	var sb strings.Builder
+	for _, addr := range path {
+		sb.WriteString("> " + addr + " ")
+	}
+	if n := len(r.Hops); n > 0 && r.Path()[n-1] == r.Ip4 {
+		fmt.Fprintf(&sb, "(reached in %d hops)", r.Hops[n-1].TTL)
+	} else {
+		fmt.Fprintf(&sb, "(not reached in %d hops)", n)
+	}
+	return sb.String()
+}
+
+// add records a finished probe under its hop.
+func (r *Report) add(pto *Proto) {
+	r.mu.Lock()         // Lock for thread-safe hop access.
+	defer r.mu.Unlock() // Unlock after hop access.
+	for i := range r.Hops {
+		if r.Hops[i].TTL == pto.TTL {
+			r.Hops[i].Probes = append(r.Hops[i].Probes, pto)
+			return
+		}
+	}
+	r.Hops = append(r.Hops, HopReport{TTL: pto.TTL, Probes: []*Proto{pto}})
+}
+
+// finish orders the recorded probes and computes the statistics.
+func (r *Report) finish() {
+	r.mu.Lock()         // Lock for thread-safe hop access.
+	defer r.mu.Unlock() // Unlock after hop access.
+	sort.Slice(r.Hops, func(i, j int) bool { return r.Hops[i].TTL < r.Hops[j].TTL })
+	var all []*Proto
+	for i := range r.Hops {
+		probes := r.Hops[i].Probes
+		sort.Slice(probes, func(i, j int) bool { return probes[i].Seq < probes[j].Seq })
+		r.Hops[i].Statistics = newStatistics(probes)
+		all = append(all, probes...)
+	}
+	r.Statistics = newStatistics(all)
+}
+
+// newStatistics computes statistics over probes.
+func newStatistics(probes []*Proto) Statistics {
+	c := newCounter()
+	for _, pto := range probes {
+		c.add(pto)
+	}
+	return c.get()
+}
+
+// counter accumulates statistics as probes finish.
+type counter struct {
+	mu  *sync.Mutex   // Mutex for thread-safe access to the statistics.
+	s   Statistics    // Statistics so far.
+	sum time.Duration // Sum of the RTTs of all replies.
+	sq  float64       // Sum of the squared RTTs of all replies in ns², for StdDev.
+}
+
+// newCounter creates an empty statistics counter.
+func newCounter() *counter { return &counter{mu: &sync.Mutex{}} }
+
+// add accounts for a finished probe.
+func (c *counter) add(pto *Proto) {
+	c.mu.Lock()         // Lock for thread-safe statistics access.
+	defer c.mu.Unlock() // Unlock after statistics access.
+	s := &c.s
+	if pto.Unreached {
+		return // The final event of an unreached trace is no probe.
+	}
+	if pto.Dup || pto.OutOfOrder {
+		if pto.Dup {
+			s.Duplicates++ // The probe was counted with its first reply.
+		} else {
+			s.OutOfOrder++ // The probe was counted as lost when it timed out.
+		}
+		s.BytesReceived += int64(pto.recvBytes)
+		return
+	}
+	s.Transmitted++
+	s.BytesSent += int64(pto.sentBytes)
+	s.BytesReceived += int64(pto.recvBytes)
+	if pto.IsError() {
+		s.Errors++ // The probe was rejected rather than answered.
+	} else if !pto.IsTimeout() {
+		s.Received++
+		if pto.Late {
+			s.Late++
+		}
+		c.sum += pto.Rtt
+		c.sq += float64(pto.Rtt) * float64(pto.Rtt)
+		if s.Min == 0 || pto.Rtt < s.Min {
+			s.Min = pto.Rtt
+		}
+		if pto.Rtt > s.Max {
+			s.Max = pto.Rtt
+		}
+		s.Avg = c.sum / time.Duration(s.Received)
+		mean := float64(c.sum) / float64(s.Received)
+		s.StdDev = time.Duration(math.Sqrt(math.Max(c.sq/float64(s.Received)-mean*mean, 0))) // Clamp rounding below 0.
+	}
+	s.Loss = float64(s.Transmitted-s.Received) / float64(s.Transmitted) * 100
+}
+
+// get returns a snapshot of the statistics.
+func (c *counter) get() Statistics {
+	c.mu.Lock()         // Lock for thread-safe statistics access.
+	defer c.mu.Unlock() // Unlock after statistics access.
+	return c.s
+}
+
+// reset clears the statistics.
+func (c *counter) reset() {
+	c.mu.Lock()         // Lock for thread-safe statistics access.
+	defer c.mu.Unlock() // Unlock after statistics access.
+	c.s, c.sum, c.sq = Statistics{}, 0, 0
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReportFinish(t *testing.T) {
+	r := &Report{mu: &sync.Mutex{}, Status: StatusCompleted}
+	r.add(pongProto(2, 1, 1, nil, "10.0.0.2", 30*time.Millisecond))
+	r.add(pongProto(1, 1, 0, nil, "10.0.0.1", 10*time.Millisecond))
+	r.add(pongProto(2, 1, 0, nil, "10.0.0.2", 10*time.Millisecond))
+	r.add(timeoutProto(1, 1, 1))
+	r.finish()
+
+	if len(r.Hops) != 2 || r.Hops[0].TTL != 1 || r.Hops[1].TTL != 2 {
+		t.Fatalf("Hops = %+v; want TTL 1 then 2", r.Hops)
+	}
+	if p := r.Hops[1].Probes; p[0].Seq != 0 || p[1].Seq != 1 {
+		t.Errorf("hop 2 probes not in sequence order: %v", p)
+	}
+	want := Statistics{Transmitted: 2, Received: 1, Loss: 50, Min: 10 * time.Millisecond, Avg: 10 * time.Millisecond, Max: 10 * time.Millisecond}
+	if r.Hops[0].Statistics != want {
+		t.Errorf("hop 1 statistics = %+v; want %+v", r.Hops[0].Statistics, want)
+	}
+	want = Statistics{Transmitted: 4, Received: 3, Loss: 25, Min: 10 * time.Millisecond, Avg: 50 * time.Millisecond / 3, Max: 30 * time.Millisecond,
+		StdDev: 9428090 * time.Nanosecond} // sqrt(800/9) ms, truncated to the nanosecond.
+	if r.Statistics != want {
+		t.Errorf("statistics = %+v; want %+v", r.Statistics, want)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	if !strings.Contains(string(data), `"status":"completed"`) {
+		t.Errorf("json = %s; want status by name", data)
+	}
+}
+
+func TestReportPath(t *testing.T) {
+	r := &Report{mu: &sync.Mutex{}}
+	r.add(pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond))
+	r.add(timeoutProto(2, 2, 0))
+	r.add(timeoutProto(2, 2, 1))
+	r.add(pongProto(3, 3, 0, nil, "10.0.1.1", time.Millisecond))
+	r.add(pongProto(3, 3, 1, nil, "10.0.2.1", time.Millisecond))
+	r.add(pongProto(3, 3, 2, nil, "10.0.2.1", time.Millisecond))
+	r.finish()
+
+	want := []string{"10.0.0.1", "*", "10.0.2.1"}
+	if got := r.Path(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path() = %v; want %v", got, want)
+	}
+}
+
+func TestReportPathSummary(t *testing.T) {
+	r := &Report{mu: &sync.Mutex{}, Ip4: "10.0.2.1"}
+	r.add(pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond))
+	r.add(timeoutProto(2, 2, 0))
+	r.add(pongProto(3, 3, 0, nil, "10.0.2.1", time.Millisecond))
+	r.finish()
+	if got, want := r.PathSummary(), "> 10.0.0.1 > * > 10.0.2.1 (reached in 3 hops)"; got != want {
+		t.Errorf("PathSummary() = %q; want %q", got, want)
+	}
+
+	r.Ip4 = "8.8.8.8"
+	if got, want := r.PathSummary(), "> 10.0.0.1 > * > 10.0.2.1 (not reached in 3 hops)"; got != want {
+		t.Errorf("PathSummary() = %q; want %q", got, want)
+	}
+}
+
+func TestReportDedupPath(t *testing.T) {
+	r := &Report{mu: &sync.Mutex{}, Ip4: "8.8.8.8"}
+	r.add(pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond))
+	r.add(pongProto(2, 2, 0, nil, "10.0.1.1", time.Millisecond))
+	r.add(pongProto(3, 3, 0, nil, "10.0.1.1", time.Millisecond))
+	r.add(timeoutProto(4, 4, 0))
+	r.add(timeoutProto(5, 5, 0))
+	r.add(pongProto(6, 6, 0, nil, "8.8.8.8", time.Millisecond))
+	r.finish()
+
+	want := []PathHop{
+		{Addr: "10.0.0.1", TTL: 1, LastTTL: 1},
+		{Addr: "10.0.1.1", TTL: 2, LastTTL: 3},
+		{Addr: "*", TTL: 4, LastTTL: 4},
+		{Addr: "*", TTL: 5, LastTTL: 5},
+		{Addr: "8.8.8.8", TTL: 6, LastTTL: 6},
+	}
+	if got := r.DedupPath(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DedupPath() = %v; want %v", got, want)
+	}
+	if got, want := r.DedupPathSummary(), "> 10.0.0.1 > 10.0.1.1 (ttl 2-3) > * > * > 8.8.8.8 (reached in 6 hops)"; got != want {
+		t.Errorf("DedupPathSummary() = %q; want %q", got, want)
+	}
+}
+
+func TestCounterLate(t *testing.T) {
+	c := newCounter()
+	late := pongProto(1, 1, 0, nil, "10.0.0.1", 900*time.Millisecond)
+	late.Late = true
+	c.add(late)
+	c.add(pongProto(1, 1, 1, nil, "10.0.0.1", time.Millisecond))
+	if s := c.get(); s.Received != 2 || s.Late != 1 || s.Loss != 0 {
+		t.Errorf("Received, Late, Loss = %d, %d, %v; want 2, 1, 0", s.Received, s.Late, s.Loss)
+	}
+}
+
+func TestCounterDuplicates(t *testing.T) {
+	c := newCounter()
+	c.add(pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond))
+	dup := pongProto(1, 1, 0, nil, "10.0.0.1", 2*time.Millisecond)
+	dup.Dup = true
+	c.add(dup)
+	if s := c.get(); s.Transmitted != 1 || s.Received != 1 || s.Duplicates != 1 || s.Max != time.Millisecond {
+		t.Errorf("Transmitted, Received, Duplicates, Max = %d, %d, %d, %v; want 1, 1, 1, 1ms", s.Transmitted, s.Received, s.Duplicates, s.Max)
+	}
+}
+
+func TestCounterBytes(t *testing.T) {
+	c := newCounter()
+	reply := pongProto(1, 1, 0, nil, "10.0.0.1", time.Millisecond)
+	reply.sentBytes, reply.recvBytes = 64, 64
+	timeout := timeoutProto(1, 1, 1)
+	timeout.sentBytes = 64
+	c.add(reply)
+	c.add(timeout)
+	if s := c.get(); s.BytesSent != 128 || s.BytesReceived != 64 {
+		t.Errorf("bytes sent/received = %d/%d; want 128/64", s.BytesSent, s.BytesReceived)
+	}
+}
+
+func TestCounterStdDev(t *testing.T) {
+	c := newCounter()
+	for i, ms := range []int{2, 4, 4, 4, 5, 5, 7, 9} {
+		c.add(pongProto(1, 1, i, nil, "10.0.0.1", time.Duration(ms)*time.Millisecond))
+	}
+	c.add(timeoutProto(1, 1, 8)) // Timeouts don't count towards the RTT statistics.
+	s := c.get()
+	if s.Avg != 5*time.Millisecond || s.StdDev != 2*time.Millisecond {
+		t.Errorf("avg/stddev = %v/%v; want 5ms/2ms", s.Avg, s.StdDev)
+	}
+	if s.Transmitted != 9 || s.Received != 8 {
+		t.Errorf("transmitted/received = %d/%d; want 9/8", s.Transmitted, s.Received)
+	}
+	c.reset()
+	c.add(pongProto(1, 1, 0, nil, "10.0.0.1", 3*time.Millisecond))
+	if s := c.get(); s.StdDev != 0 {
+		t.Errorf("stddev of a single reply after reset = %v; want 0", s.StdDev)
+	}
+}
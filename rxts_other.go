@@ -0,0 +1,28 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package icmpkg
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// setRxTimestamps reports that kernel receive timestamps can't be enabled; only Linux is supported so far.
+func setRxTimestamps(syscall.RawConn) error {
+	return errors.New("kernel receive timestamps are not supported on this platform")
+}
+
+// rxTimestamp never finds a timestamp, as none are enabled.
+func rxTimestamp([]byte) (time.Time, bool) { return time.Time{}, false }
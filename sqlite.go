@@ -0,0 +1,80 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// sqliteSchema creates the table SQLiteSink inserts probes into.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS icmpkg_probes (
+	time    TEXT    NOT NULL,
+	target  TEXT    NOT NULL,
+	tag     TEXT    NOT NULL,
+	ttl     INTEGER NOT NULL,
+	seq     INTEGER NOT NULL,
+	hop     TEXT    NOT NULL,
+	rtt_ms  REAL,
+	result  TEXT    NOT NULL
+)`
+
+// sqliteInsert inserts a probe into the icmpkg_probes table.
+const sqliteInsert = `INSERT INTO icmpkg_probes (time, target, tag, ttl, seq, hop, rtt_ms, result) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SQLiteSink persists every probe as a row of the icmpkg_probes table in db, creating the table if needed,
+// for historical analysis, e.g. SELECT avg(rtt_ms) FROM icmpkg_probes WHERE target = '8.8.8.8'. Rows hold
+// the time the probe finished (RFC 3339 in UTC), the target, the Tag, the TTL, the sequence number, the
+// replying address, the RTT in milliseconds (NULL for a timeout) and the result, "reply", "late" (see
+// LateGrace), "unreachable", "frag_needed" (see DontFragment), "duplicate" (see Proto.Dup),
+// "out_of_order" (see Proto.OutOfOrder) or "timeout".
+//
+// The package imports no database driver, so SQLite's cgo or driver dependency stays opt-in: open db with
+// the driver of your choice, such as modernc.org/sqlite or github.com/mattn/go-sqlite3. Rows that fail to
+// insert are counted by SQLiteErrors rather than stopping the operation.
+func (tr *traceroute) SQLiteSink(db *sql.DB) error {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("icmpkg: create sqlite table: %w", err)
+	}
+	tr.ProbeHook(func(pto *Proto) {
+		result, rtt := "reply", sql.NullFloat64{Float64: float64(pto.Rtt) / float64(time.Millisecond), Valid: true}
+		if pto.Late {
+			result = "late" // The reply arrived within the LateGrace window.
+		}
+		if pto.FragNeeded {
+			result = "frag_needed" // A hop dropped the probe as too big.
+		}
+		if pto.Unreachable {
+			result = "unreachable" // A hop rejected the probe.
+		}
+		if pto.Dup {
+			result = "duplicate" // The probe was answered before.
+		}
+		if pto.OutOfOrder {
+			result = "out_of_order" // The probe was recorded as a timeout before.
+		}
+		if pto.IsTimeout() {
+			result, rtt = "timeout", sql.NullFloat64{} // Timeouts have no RTT.
+		}
+		_, err := db.Exec(sqliteInsert, pto.Time.UTC().Format(time.RFC3339Nano), tr.address, tr.tag, pto.TTL, pto.Seq, pto.Ip4, rtt, result)
+		if err != nil {
+			atomic.AddInt32(&tr.sqliteErrors, 1)           // Count the lost row.
+			tr.debug("SQLiteSink() insert error: %v", err) // Log and keep probing.
+		}
+	})
+	return nil
+}
+
+// SQLiteErrors returns the number of probes SQLiteSink failed to insert since the operation was created.
+func (tr *traceroute) SQLiteErrors() int { return int(atomic.LoadInt32(&tr.sqliteErrors)) }
@@ -0,0 +1,92 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordDriver is a database/sql driver that records the arguments of every INSERT instead of storing them.
+type recordDriver struct {
+	mu   sync.Mutex
+	rows [][]driver.Value
+}
+
+func (d *recordDriver) Open(string) (driver.Conn, error) { return recordConn{d}, nil }
+
+// Connect and Driver make the driver its own connector, so each test opens a fresh one with sql.OpenDB
+// rather than registering it, which can only be done once per process.
+func (d *recordDriver) Connect(context.Context) (driver.Conn, error) { return recordConn{d}, nil }
+func (d *recordDriver) Driver() driver.Driver                        { return d }
+
+type recordConn struct{ d *recordDriver }
+
+func (c recordConn) Prepare(query string) (driver.Stmt, error) { return recordStmt{c.d, query}, nil }
+func (c recordConn) Close() error                              { return nil }
+func (c recordConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+func (s recordStmt) Close() error  { return nil }
+func (s recordStmt) NumInput() int { return -1 }
+func (s recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(s.query, "INSERT") {
+		s.d.mu.Lock()
+		defer s.d.mu.Unlock()
+		s.d.rows = append(s.d.rows, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s recordStmt) Query([]driver.Value) (driver.Rows, error) { return nil, errors.New("no queries") }
+
+func TestSQLiteSink(t *testing.T) {
+	d := &recordDriver{}
+	db := sql.OpenDB(d)
+	defer db.Close()
+
+	tr := Traceroute("127.0.0.1", 3, 1)
+	tr.Tag("run-1")
+	if err := tr.SQLiteSink(db); err != nil {
+		t.Fatalf("SQLiteSink() error: %v", err)
+	}
+	reply := pongProto(2, 7, 0, nil, "10.0.0.1", 1500*time.Microsecond)
+	reply.Time = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tr.handle(reply)
+	tr.handle(timeoutProto(3, 7, 1))
+
+	if len(d.rows) != 2 {
+		t.Fatalf("SQLiteSink() inserted %d rows; want 2", len(d.rows))
+	}
+	want := []driver.Value{"2025-01-02T03:04:05Z", "127.0.0.1", "run-1", int64(2), int64(0), "10.0.0.1", 1.5, "reply"}
+	for i, v := range want {
+		if d.rows[0][i] != v {
+			t.Errorf("reply column %d = %v; want %v", i, d.rows[0][i], v)
+		}
+	}
+	if rtt, result := d.rows[1][6], d.rows[1][7]; rtt != nil || result != "timeout" {
+		t.Errorf("timeout rtt_ms, result = %v, %v; want NULL, timeout", rtt, result)
+	}
+	if got := tr.SQLiteErrors(); got != 0 {
+		t.Errorf("SQLiteErrors() = %d; want 0", got)
+	}
+}
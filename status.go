@@ -0,0 +1,50 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//      http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmpkg
+
+// RunStatus describes why a ping or traceroute operation ended.
+type RunStatus int32
+
+// Completion statuses reported by Status after Run returns.
+const (
+	StatusNone      RunStatus = iota // The operation hasn't finished yet.
+	StatusCompleted                  // All packets were sent and handled.
+	StatusStopped                    // Stop was called before completion.
+	StatusCancelled                  // The context passed to Context was cancelled.
+	StatusDeadline                   // The duration passed to Deadline elapsed.
+	StatusError                      // The packet layer failed, e.g. the socket was closed underneath the run.
+	StatusUnreached                  // A traceroute probed up to its maximum TTL without the destination replying.
+)
+
+// String returns a human-readable name for the status.
+func (s RunStatus) String() string {
+	switch s {
+	case StatusNone:
+		return "none"
+	case StatusCompleted:
+		return "completed"
+	case StatusStopped:
+		return "stopped"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusDeadline:
+		return "deadline"
+	case StatusError:
+		return "error"
+	case StatusUnreached:
+		return "unreached"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the status by name, so reports marshal it as e.g. "completed" rather than a number.
+func (s RunStatus) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
@@ -13,6 +13,8 @@ package icmpkg
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	logpkg "log"
 	"net"
@@ -22,41 +24,194 @@ import (
 	"time"
 )
 
+// defaultVerifyDepth is the number of leading payload bytes verified when VerifyPayload is given no depth.
+const defaultVerifyDepth = 64
+
+// defaultDuration is the write and read duration of the convenience constructors unless changed with
+// SetDefaultWriteDuration and SetDefaultReadDuration.
+const defaultDuration = time.Millisecond * 500
+
+// maxPayload is the largest Echo payload an IPv4 packet can carry, past its IP and ICMP headers.
+const maxPayload = 65535 - ip4HeaderLen - 8
+
 // Global variables for ICMP ID generation and debug/trace logging.
 var (
 	icmpId          = uint32(os.Getpid() & 0xffff)                                // Initial ICMP ID derived from process ID, masked to 16 bits.
+	defaultWriteDur = int64(defaultDuration)                                      // Write duration of the convenience constructors, accessed atomically.
+	defaultReadDur  = int64(defaultDuration)                                      // Read duration of the convenience constructors, accessed atomically.
 	tracerouteDebug = func() bool { return os.Getenv("TRACEROUTE_DEBUG") == "T" } // Enables debug logging if TRACEROUTE_DEBUG is set to "T".
 	tracerouteTrace = func() bool { return os.Getenv("TRACEROUTE_TRACE") == "T" } // Enables trace logging if TRACEROUTE_TRACE is set to "T".
 )
 
-// nextIcmpId generates the next ICMP ID, incrementing atomically and wrapping around at 2^15.
-func nextIcmpId() uint32 { return atomic.AddUint32(&icmpId, 1) % (2 << 15) }
+// nextIcmpId generates the next ICMP ID, incrementing atomically and wrapping around at 2^16.
+// Zero is skipped because replies carrying ID 0 are ignored.
+func nextIcmpId() uint32 {
+	for {
+		if id := atomic.AddUint32(&icmpId, 1) % (2 << 15); id != 0 {
+			return id
+		}
+	}
+}
+
+// SeedIcmpId mixes salt into the process-derived starting ICMP ID, so that independent processes whose
+// PIDs share the same low 16 bits can be given distinct ID ranges.
+func SeedIcmpId(salt uint32) { atomic.StoreUint32(&icmpId, (uint32(os.Getpid())^salt)&0xffff) }
+
+// SetDefaultWriteDuration sets the write duration of operations created by Ping, PingFor, Traceroute,
+// MultiPing, TracerouteAll and Healthcheck, process-wide; it applies to operations created afterwards.
+// A duration <= 0 restores the default of 500ms.
+func SetDefaultWriteDuration(d time.Duration) {
+	atomic.StoreInt64(&defaultWriteDur, int64(orDefault(d)))
+}
+
+// SetDefaultReadDuration sets the read duration of operations created by the same constructors like
+// SetDefaultWriteDuration. PingFor and Healthcheck still shorten or lengthen it as they document.
+func SetDefaultReadDuration(d time.Duration) {
+	atomic.StoreInt64(&defaultReadDur, int64(orDefault(d)))
+}
+
+// orDefault returns d, or defaultDuration if d <= 0.
+func orDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultDuration
+	}
+	return d
+}
+
+// defaultDurations returns the write and read durations of the convenience constructors.
+func defaultDurations() (writeDur, readDur time.Duration) {
+	return time.Duration(atomic.LoadInt64(&defaultWriteDur)), time.Duration(atomic.LoadInt64(&defaultReadDur))
+}
+
+// RandomIcmpId seeds the ICMP ID generator with a random salt, making cross-process ID collisions unlikely.
+func RandomIcmpId() {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return // Keep the PID-derived IDs if no randomness is available.
+	}
+	SeedIcmpId(binary.BigEndian.Uint32(b[:]))
+}
 
 // traceroute manages ICMP-based ping or traceroute operations with configuration and synchronization.
 type traceroute struct {
-	lo                    *logpkg.Logger    // Logger instance for debug and trace output.
-	address               string            // Target address for ping/traceroute.
-	addr                  net.Addr          // Resolved network address of the target.
-	ip4                   string            // IPv4 address as a string.
-	maxTTL, maxHop, count int               // Maximum TTL, maximum hops, and number of packets to send.
-	writeDur, readDur     time.Duration     // Durations for write and read timeouts.
-	wc, rc, hc            chan *Proto       // Channels for writing, reading, and handling Proto messages.
-	id                    []int             // Array of ICMP IDs for each TTL.
-	ic                    []chan *Proto     // Array of channels for receiving Proto messages per TTL.
-	pec, hec, cec         chan struct{}     // Channels for signaling pong, handler, and context termination.
-	runOnce, stopOnce     *sync.Once        // Ensure Run and Stop are executed only once.
-	exit                  bool              // Flag to indicate termination.
-	pongHandler           func(pong *Proto) // Optional callback for handling pong responses.
-	ctx                   context.Context   // Context for cancellation.
-	packet                *packet           // Packet handler for ICMP communication.
-	wg                    *sync.WaitGroup   // WaitGroup for synchronizing goroutines.
-	traceroute            bool              // Flag to indicate traceroute (true) or ping (false) mode.
-}
-
-// Traceroute creates a traceroute instance with default write and read durations of 500ms.
+	lo                *logpkg.Logger       // Logger instance for debug and trace output.
+	address           string               // Target address for ping/traceroute.
+	addr              net.Addr             // Resolved network address of the target.
+	ip4               string               // IPv4 address as a string.
+	maxTTL, count     int                  // Maximum TTL and number of packets to send.
+	maxHop            int32                // Maximum hops, lowered once the destination replies; accessed atomically.
+	writeDur, readDur time.Duration        // Durations for write and read timeouts.
+	wc, rc, hc        chan *Proto          // Channels for writing, reading, and handling Proto messages.
+	id                []int                // Array of ICMP IDs for each TTL.
+	ic                []chan *Proto        // Array of channels for receiving Proto messages per TTL.
+	sent              []time.Time          // Send time of the latest probe per TTL, the reference for pacing.
+	started           []time.Time          // Send time of the first probe per TTL, the reference for ReplyRate.
+	replies           []int                // Number of replies per TTL, counted for ReplyRate.
+	hop               map[int]int          // Map of ICMP ID to TTL index, used to route replies.
+	mu                *sync.Mutex          // Mutex for thread-safe access to the hop map.
+	pec, hec, cec     chan struct{}        // Channels for signaling pong, handler, and context termination.
+	runOnce, stopOnce *sync.Once           // Ensure Run and Stop are executed only once.
+	done              chan struct{}        // Closed once the operation stops, waking every goroutine blocked on it.
+	handled           chan struct{}        // Closed once the handler goroutine exited, after handling every Proto.
+	pongHandler       func(pong *Proto)    // Optional callback for handling pong responses.
+	ctx               context.Context      // Context for cancellation.
+	packet            transport            // Packet I/O: the local packet handler, or a relay to a remote agent.
+	wg                *sync.WaitGroup      // WaitGroup for synchronizing goroutines.
+	traceroute        bool                 // Flag to indicate traceroute (true) or ping (false) mode.
+	pcapFile          string               // Optional pcap file recording sent and received packets.
+	deadline          time.Duration        // Optional wall-clock limit for the whole operation.
+	budget            int                  // Optional total probe budget for traceroute, weighted by TTL.
+	destCount         int                  // Number of probes to the destination's hop in traceroute mode; 0 uses the per-hop count.
+	status            int32                // RunStatus recorded when the operation stops, accessed atomically.
+	reconnects        int                  // Number of times the socket may be re-opened after failing.
+	probeHooks        []func(*Proto)       // Hooks invoked for every finished probe, before the pong handler.
+	size, verify      int                  // Echo payload size and number of payload bytes verified in replies.
+	report            *Report              // Report collecting every probe when run through RunReport.
+	geo               GeoLookupFunc        // Optional geolocation lookup annotating replies.
+	interval          time.Duration        // Optional pacing between probes of the same TTL; defaults to readDur.
+	onResolve         func(string, net.IP) // Optional callback reporting what the target resolved to.
+	reached           int32                // Set atomically once the destination replied in traceroute mode.
+	notifyUnreached   bool                 // Whether to emit a final Unreached event when the destination never replies.
+	interleave        bool                 // Whether probes to different hops are spread evenly over each pacing period.
+	giveUp            int                  // Consecutive unanswered hops after which a traceroute stops probing further; 0 never gives up.
+	epoch             time.Time            // Start of the run, the reference for interleaving slots.
+	stats             *counter             // Statistics accumulated over all runs since the last Reset.
+	bg                *sync.WaitGroup      // WaitGroup for the background goroutines of a run.
+	seqStart          int                  // Sequence number reported for the first probe of each TTL.
+	tag               string               // Opaque run ID set on every Proto and prefixed to debug logs.
+	handlerTimeout    time.Duration        // Time a probe hook or pong handler may take before it is skipped; 0 waits forever.
+	handlerTimeouts   int32                // Number of handler invocations that exceeded handlerTimeout in the current run.
+	source            net.IP               // Local address the socket is bound to, so probes leave from it; nil lets the kernel choose.
+	bpf               bool                 // Whether to filter replies by ICMP ID in the kernel.
+	v6                bool                 // Whether the target resolved to an IPv6 address, switching the packet layer to ICMPv6.
+	replyRate         float64              // Target replies per second per TTL; 0 paces probes at a fixed interval.
+	data              []byte               // Explicit Echo payload set with PayloadData, replacing the generated pattern.
+	results           chan *Proto          // Channel returned by Results for the current run, closed when the run ends; nil if unused.
+	parallel          bool                 // Whether a traceroute probes all TTLs concurrently instead of hop by hop.
+	uniform           bool                 // Whether the first probe of every TTL is sent and handled like the rest.
+	sqliteErrors      int32                // Number of probes SQLiteSink failed to insert, accessed atomically.
+	lateGrace         time.Duration        // Time after the read timeout within which a reply is still credited as late.
+	names             *nameCache           // Reverse DNS cache annotating replies with host names; nil if disabled.
+	relay             RelayDialer          // Opens the connection to a remote agent doing the packet I/O; nil probes locally.
+	tos               int                  // Type-of-Service byte of the probes, set with TOS; 0 keeps the default.
+	df                bool                 // Whether IPv4 probes are sent with the Don't Fragment bit set.
+	rxts              bool                 // Whether replies are timed with kernel receive timestamps.
+	pauseMu           *sync.Mutex          // Mutex guarding resume.
+	resume            chan struct{}        // Closed when a paused operation resumes; nil while not paused.
+}
+
+// init initializes the state used by a single Run.
+func (tr *traceroute) init() {
+	tr.maxHop = int32(tr.maxTTL)              // Set maximum hops (initially equal to maxTTL).
+	tr.wc = make(chan *Proto, 1)              // Initialize write channel.
+	tr.rc = make(chan *Proto, 1)              // Initialize read channel.
+	tr.hc = make(chan *Proto, 1)              // Initialize handler channel.
+	tr.id = make([]int, tr.maxTTL)            // Initialize ICMP ID array.
+	tr.ic = make([]chan *Proto, tr.maxTTL)    // Initialize per-TTL Proto channels.
+	tr.sent = make([]time.Time, tr.maxTTL)    // Initialize per-TTL send times.
+	tr.started = make([]time.Time, tr.maxTTL) // Initialize per-TTL first send times.
+	tr.replies = make([]int, tr.maxTTL)       // Initialize per-TTL reply counts.
+	tr.hop = make(map[int]int)                // Initialize ID to TTL map.
+	tr.pec = make(chan struct{}, 1)           // Initialize pong exit channel.
+	tr.hec = make(chan struct{}, 1)           // Initialize handler exit channel.
+	tr.runOnce = &sync.Once{}                 // Initialize Run once guard.
+	tr.stopOnce = &sync.Once{}                // Initialize Stop once guard.
+	tr.wg = &sync.WaitGroup{}                 // Initialize WaitGroup for goroutine synchronization.
+	tr.done = make(chan struct{})             // Initialize stop signal.
+	tr.handled = make(chan struct{})          // Initialize handler exit signal.
+	tr.packet = nil                           // Drop the previous packet handler.
+	tr.report = nil                           // Drop the previous report collector.
+	tr.results = nil                          // Drop the previous results channel.
+	atomic.StoreInt32(&tr.status, 0)          // Clear the completion status.
+	atomic.StoreInt32(&tr.reached, 0)         // Clear the destination reached flag.
+	atomic.StoreInt32(&tr.handlerTimeouts, 0) // Clear the handler timeout count.
+	if tr.ctx != nil {
+		tr.cec = make(chan struct{}, 1) // Initialize context exit channel.
+	}
+}
+
+// Reset prepares the operation to Run again, e.g. from a monitoring loop. It waits for the goroutines
+// of the previous Run to exit, so it must not be called while Run is in progress. Statistics returned
+// by Stats accumulate across runs if keepStats is true and start fresh otherwise. Options are kept.
+func (tr *traceroute) Reset(keepStats bool) {
+	tr.bg.Wait() // Wait for the previous run to wind down.
+	tr.init()    // Re-initialize per-run state.
+	if !keepStats {
+		tr.stats.reset() // Start the statistics afresh.
+	}
+}
+
+// Stats returns the statistics of all probes finished since the operation was created or last Reset
+// without keeping statistics.
+func (tr *traceroute) Stats() Statistics { return tr.stats.get() }
+
+// Traceroute creates a traceroute instance with default write and read durations of 500ms, see
+// SetDefaultWriteDuration and SetDefaultReadDuration. A count <= 0 keeps probing every hop until stopped,
+// like mtr.
 func Traceroute(address string, maxTTL, count int) *traceroute {
 	// Initialize traceroute with default durations for write and read operations.
-	return TracerouteDuration(address, maxTTL, count, time.Millisecond*500, time.Millisecond*500)
+	writeDur, readDur := defaultDurations()
+	return TracerouteDuration(address, maxTTL, count, writeDur, readDur)
 }
 
 // TracerouteDuration creates a traceroute instance with specified write and read durations.
@@ -68,26 +223,24 @@ func TracerouteDuration(address string, maxTTL, count int, writeDur, readDur tim
 // newTraceroute initializes a traceroute instance with the given configuration.
 func newTraceroute(address string, maxTTL, count int, writeDur, readDur time.Duration, route bool) *traceroute {
 	tr := &traceroute{
-		address:    address,                     // Set target address.
-		maxTTL:     maxTTL,                      // Set maximum TTL.
-		maxHop:     maxTTL,                      // Set maximum hops (initially equal to maxTTL).
-		count:      count,                       // Set number of packets to send per TTL.
-		writeDur:   writeDur,                    // Set write timeout duration.
-		readDur:    readDur,                     // Set read timeout duration.
-		wc:         make(chan *Proto, 1),        // Initialize write channel.
-		rc:         make(chan *Proto, 1),        // Initialize read channel.
-		hc:         make(chan *Proto, 1),        // Initialize handler channel.
-		id:         make([]int, maxTTL),         // Initialize ICMP ID array.
-		ic:         make([]chan *Proto, maxTTL), // Initialize per-TTL Proto channels.
-		pec:        make(chan struct{}, 1),      // Initialize pong exit channel.
-		hec:        make(chan struct{}, 1),      // Initialize handler exit channel.
-		runOnce:    &sync.Once{},                // Initialize Run once guard.
-		stopOnce:   &sync.Once{},                // Initialize Stop once guard.
-		wg:         &sync.WaitGroup{},           // Initialize WaitGroup for goroutine synchronization.
-		traceroute: route,                       // Set traceroute or ping mode.
+		address:    address,           // Set target address.
+		maxTTL:     maxTTL,            // Set maximum TTL.
+		count:      count,             // Set number of packets to send per TTL.
+		writeDur:   writeDur,          // Set write timeout duration.
+		readDur:    readDur,           // Set read timeout duration.
+		mu:         &sync.Mutex{},     // Initialize mutex for the hop map.
+		pauseMu:    &sync.Mutex{},     // Initialize mutex for the pause gate.
+		stats:      newCounter(),      // Initialize accumulated statistics.
+		bg:         &sync.WaitGroup{}, // Initialize WaitGroup for background goroutines.
+		traceroute: route,             // Set traceroute or ping mode.
 	}
+	tr.init() // Initialize per-run state.
 	// Resolve the target address and its IPv4 string representation.
 	tr.addr, tr.ip4 = ip4(address)
+	if addrIP(tr.addr) == nil {
+		tr.addr, tr.ip4 = ip6(address) // Fall back to IPv6 for hosts without an IPv4 address.
+	}
+	tr.v6 = isV6(tr.addr) // Pick the address family from the resolved address.
 	// Set up logger for ping mode if debug or trace is enabled.
 	if !route && (pingDebug() || pingTrace()) {
 		tr.lo = logpkg.New(os.Stdout, fmt.Sprintf("[ping:%-24s] ", tr.address), logpkg.LstdFlags)
@@ -99,6 +252,14 @@ func newTraceroute(address string, maxTTL, count int, writeDur, readDur time.Dur
 	return tr
 }
 
+// mode returns the name of the operation's mode, "ping" or "route", as used in its log prefix.
+func (tr *traceroute) mode() string {
+	if tr.traceroute {
+		return "route"
+	}
+	return "ping"
+}
+
 // debug logs a debug message if debug mode is enabled for ping or traceroute.
 func (tr *traceroute) debug(format string, arg ...any) {
 	if tr.traceroute && tracerouteDebug() {
@@ -122,41 +283,504 @@ func (tr *traceroute) trace(format string, arg ...any) {
 // Addr returns the resolved network address of the target.
 func (tr *traceroute) Addr() net.Addr { return tr.addr }
 
-// Ip4 returns the IPv4 address of the target as a string.
+// Ip4 returns the IPv4 address of the target as a string, or its IPv6 address if IsV6 reports true.
 func (tr *traceroute) Ip4() string { return tr.ip4 }
 
+// IsV6 reports whether the target resolved to an IPv6 address and is probed over ICMPv6.
+func (tr *traceroute) IsV6() bool { return tr.v6 }
+
 // Context sets the context for cancellation and initializes the context exit channel.
 func (tr *traceroute) Context(ctx context.Context) {
 	tr.ctx = ctx
 	tr.cec = make(chan struct{}, 1)
 }
 
-// PongHandler sets the callback function for handling pong responses.
+// PongHandler sets the callback function for handling pong responses. The handler may retain the Proto
+// it receives, see Proto.
 func (tr *traceroute) PongHandler(handler func(pong *Proto)) { tr.pongHandler = handler }
 
+// RunHandler sets the pong handler like PongHandler and runs the operation like Run, in one call. Unlike
+// Run, it returns an error instead of panicking if the socket can't be opened, and when the packet layer
+// failed during the run; Status tells how the operation ended otherwise.
+func (tr *traceroute) RunHandler(handler func(pong *Proto)) (err error) {
+	defer func() {
+		if re := recover(); re != nil {
+			err = fmt.Errorf("icmpkg: %s: %v", tr.address, re) // The socket couldn't be opened.
+		}
+	}()
+	tr.PongHandler(handler)
+	tr.Run()
+	if tr.Status() == StatusError {
+		return fmt.Errorf("icmpkg: %s: the packet layer failed during the run", tr.address)
+	}
+	return nil
+}
+
+// Results returns a channel delivering every Proto handed to the pong handler, in the same order and
+// after the handler returned. The channel is closed once the run ends, also when it is stopped. Call it
+// before Run; after Reset, call it again for the next run. A consumer that stops receiving stalls the run
+// until it is stopped, as a blocking pong handler would.
+func (tr *traceroute) Results() <-chan *Proto {
+	if tr.results == nil {
+		tr.results = make(chan *Proto, 1) // Create the channel on first use.
+	}
+	return tr.results
+}
+
+// ProbeHook adds a hook invoked for every finished probe, reply or timeout, before the pong handler.
+// Unlike PongHandler, hooks accumulate, so instrumentation such as OtelTracer can coexist with it.
+func (tr *traceroute) ProbeHook(hook func(pto *Proto)) { tr.probeHooks = append(tr.probeHooks, hook) }
+
+// Interval sets the time between successive probes of the same TTL. By default probes are paced at the
+// read duration.
+func (tr *traceroute) Interval(d time.Duration) { tr.interval = d }
+
+// OnResolve sets a callback invoked when Run starts with the target as given and the IP it resolved to,
+// or nil if resolution failed.
+func (tr *traceroute) OnResolve(fn func(host string, ip net.IP)) { tr.onResolve = fn }
+
+// NotifyUnreached makes a traceroute that reaches its maximum TTL without the destination replying
+// deliver a final synthetic Proto with Unreached set to the pong handler. Status reports
+// StatusUnreached for such traces either way.
+func (tr *traceroute) NotifyUnreached(enabled bool) { tr.notifyUnreached = enabled }
+
+// Interleave spreads the probes of the different hops evenly across each pacing period in traceroute mode,
+// round-robin by TTL, instead of sending them to all hops at once. Probes to the same hop stay one interval
+// apart, and the path as a whole no longer sees bursts that trip per-source ICMP rate limits on routers,
+// which otherwise shows up as false loss on near hops.
+func (tr *traceroute) Interleave(enabled bool) { tr.interleave = enabled }
+
+// ReplyRate paces probes to receive about rate replies per second per TTL, instead of sending one probe per
+// interval. Probes are scheduled by the replies received rather than by the probes sent, so a TTL that lost
+// replies, or whose RTT exceeded the period, is probed again at once to catch up. As a TTL has one probe in
+// flight at a time, the rate achieved is at most one reply per RTT. 0 (the default) disables it.
+func (tr *traceroute) ReplyRate(rate float64) { tr.replyRate = rate }
+
+// GiveUpAfter makes a traceroute stop probing further TTLs once hops consecutive hops left their first
+// probe unanswered, so a dead network ends the trace quickly instead of timing out up to the maximum TTL.
+// Probes already started for earlier hops still finish, and Status reports StatusUnreached. 0 (the
+// default) never gives up.
+func (tr *traceroute) GiveUpAfter(hops int) { tr.giveUp = hops }
+
+// LateGrace keeps waiting up to d past the read timeout for a reply, crediting one that arrives in time as
+// received with Late set rather than counting the probe lost, so a path whose replies are consistently
+// late isn't reported as 100% loss. Statistics count such replies in both Received and Late. The next
+// probe of the TTL waits for the grace window to pass. 0 (the default) counts late replies as lost.
+func (tr *traceroute) LateGrace(d time.Duration) { tr.lateGrace = d }
+
+// Parallel makes a traceroute probe all TTLs up to the maximum at once, like mtr, instead of waiting for
+// the reply or timeout of each hop before probing the next, so an unreachable destination takes about one
+// read duration per probe rather than one per hop. Results are still delivered in TTL order: all probes
+// of a hop, duplicate and out-of-order replies included, then those of the next, dropping hops past the
+// destination once it replied. GiveUpAfter has no effect, and a probe budget is spread over the maximum
+// TTL. It has no effect in ping mode, nor with a count <= 0, as a hop probed until stopped would hold back
+// the results of the next.
+func (tr *traceroute) Parallel(enabled bool) { tr.parallel = enabled }
+
+// Uniform sends the first probe of every TTL from the TTL's goroutine like the rest of its probes, rather
+// than sending it and handling its reply before probing the next TTL, so startup isn't serialized by the
+// first probes. A traceroute probes all TTLs up to the maximum at once, as with Parallel, but results are
+// handled as they arrive rather than in TTL order, also with a count <= 0. Hops past the destination are
+// dropped once it replied. GiveUpAfter has no effect, and a probe budget is spread over the maximum TTL.
+func (tr *traceroute) Uniform(enabled bool) { tr.uniform = enabled }
+
+// SeqStart sets the sequence number reported for the first probe, 0 by default. Reported sequence numbers
+// keep increasing past 65535 for long runs, while the 16-bit sequence number on the wire wraps around.
+func (tr *traceroute) SeqStart(start int) { tr.seqStart = start }
+
+// Tag attaches an opaque run ID for log correlation. It prefixes the debug and trace logs of the
+// operation and its packet layer, and is set on every Proto, and thus on reports and structured output.
+func (tr *traceroute) Tag(id string) {
+	tr.tag = id
+	if tr.lo != nil {
+		tr.lo.SetPrefix(tagPrefix(id) + tr.lo.Prefix()) // Prefix the operation's logger.
+	}
+}
+
+// Interface binds the operation to the first address of the named network interface in the target's
+// family, so probes leave from that address and only replies to it are read. It returns an error if the
+// interface doesn't exist or has no such address.
+func (tr *traceroute) Interface(name string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("icmpkg: interface %q: %w", name, err)
+	}
+	return tr.bindInterface(iface)
+}
+
+// InterfaceIndex is like Interface but selects the interface by its index, which stays stable in
+// containers whose interface names don't.
+func (tr *traceroute) InterfaceIndex(index int) error {
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return fmt.Errorf("icmpkg: interface index %d: %w", index, err)
+	}
+	return tr.bindInterface(iface)
+}
+
+// SourceAddress binds the operation to the local address ip, so probes leave from it on a multi-homed host
+// and only replies to it are read. It returns an error if ip isn't an address of the target's family
+// assigned to a local interface.
+func (tr *traceroute) SourceAddress(ip string) error {
+	src := net.ParseIP(ip)
+	if src == nil {
+		return fmt.Errorf("icmpkg: source address %q is not an IP", ip)
+	}
+	if (src.To4() == nil) != tr.v6 {
+		return fmt.Errorf("icmpkg: source address %s doesn't match the target's address family", ip)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return fmt.Errorf("icmpkg: source address %s: %w", ip, err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(src) {
+			tr.source = src // Bind to the local address.
+			return nil
+		}
+	}
+	return fmt.Errorf("icmpkg: source address %s is not assigned to a local interface", ip)
+}
+
+// bindInterface sets the source address to the first address of iface in the target's family. Link-local
+// IPv6 addresses are skipped, as they can't reach a routed target.
+func (tr *traceroute) bindInterface(iface *net.Interface) error {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return fmt.Errorf("icmpkg: interface %s: %w", iface.Name, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil && !tr.v6 {
+			tr.source = ip4 // Bind to the first IPv4 address.
+			return nil
+		}
+		if ipNet.IP.To4() == nil && tr.v6 && !ipNet.IP.IsLinkLocalUnicast() {
+			tr.source = ipNet.IP // Bind to the first routable IPv6 address.
+			return nil
+		}
+	}
+	if tr.v6 {
+		return fmt.Errorf("icmpkg: interface %s has no IPv6 address", iface.Name)
+	}
+	return fmt.Errorf("icmpkg: interface %s has no IPv4 address", iface.Name)
+}
+
+// HandlerTimeout bounds the time the probe hooks and pong handler may take per probe, so a handler that
+// blocks can't stall the run. A handler exceeding d is logged, counted in HandlerTimeouts and left running
+// in the background while the run moves on, so later calls may overlap with it. 0 (the default) waits
+// for handlers to return.
+func (tr *traceroute) HandlerTimeout(d time.Duration) { tr.handlerTimeout = d }
+
+// HandlerTimeouts returns how many handler invocations of the current or last run exceeded the
+// HandlerTimeout.
+func (tr *traceroute) HandlerTimeouts() int { return int(atomic.LoadInt32(&tr.handlerTimeouts)) }
+
+// tagPrefix returns the log prefix for a run ID, or nothing without one.
+func tagPrefix(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "[" + id + "] "
+}
+
+// BPFFilter attaches a socket filter accepting only replies carrying this operation's ICMP IDs, so on a
+// busy host unrelated ICMP traffic is dropped by the kernel rather than read and discarded. Where socket
+// filters aren't supported (they are on Linux) replies are still filtered in userspace.
+func (tr *traceroute) BPFFilter(enabled bool) { tr.bpf = enabled }
+
+// Deadline stops the operation once d has elapsed since Run was called, regardless of the packet count.
+func (tr *traceroute) Deadline(d time.Duration) { tr.deadline = d }
+
+// ProbeBudget replaces the uniform per-hop count in traceroute mode with a total probe budget. Every hop
+// first receives one probe to discover the path; the remaining budget is then spread across the discovered
+// hops weighted by TTL, so near hops that stabilize quickly get fewer probes than distant ones.
+func (tr *traceroute) ProbeBudget(total int) { tr.budget = total }
+
+// DestinationCount sends count probes to the hop the destination answers at in traceroute mode, instead
+// of the per-hop count, e.g. to sample the destination 10 times while the hops before it get 3. It takes
+// effect once the destination replied, also with ProbeBudget, and doesn't apply to a traceroute probing
+// until stopped. 0 (the default) probes the destination like every other hop.
+func (tr *traceroute) DestinationCount(count int) { tr.destCount = count }
+
+// Reconnect lets the operation transparently re-open its ICMP socket up to retries times if the socket
+// is closed unexpectedly, instead of ending the run. Probes in flight at the time may be lost.
+func (tr *traceroute) Reconnect(retries int) { tr.reconnects = retries }
+
+// PayloadSize sets the size in bytes of the Echo payload sent with each probe; the default is no payload.
+func (tr *traceroute) PayloadSize(size int) { tr.size = size }
+
+// PayloadLen returns the size in bytes of the Echo payload sent with each probe, as set by PayloadSize,
+// PayloadData or PayloadFile.
+func (tr *traceroute) PayloadLen() int { return tr.size }
+
+// PayloadData sets the exact Echo payload sent with every probe, e.g. a recognizable signature, replacing
+// the pattern generated for PayloadSize, whose size it also sets. Echo Replies whose payload differs are
+// dropped as stray replies, guarding against mis-attributing another process's replies whose ID and
+// sequence number collide with ours. As routers may truncate the probes they quote, Time Exceeded messages
+// are only checked for the part they quote. A nil data restores the generated payload.
+func (tr *traceroute) PayloadData(data []byte) {
+	tr.data = nil
+	if data != nil {
+		tr.data = append([]byte{}, data...) // Copy so later changes by the caller don't alter probes.
+	}
+	tr.size = len(data)
+}
+
+// PayloadFile sets the Echo payload sent with every probe to the contents of the file at path, sent
+// verbatim, e.g. a crafted payload for testing whether an IDS flags it. See PayloadData; together with
+// VerifyPayload, replies report with Corrupt whether the payload round-tripped unchanged.
+func (tr *traceroute) PayloadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("icmpkg: payload file: %w", err)
+	}
+	if len(data) > maxPayload {
+		return fmt.Errorf("icmpkg: payload file %s has %d bytes, more than the %d an Echo can carry", path, len(data), maxPayload)
+	}
+	tr.PayloadData(data)
+	return nil
+}
+
+// VerifyPayload enables verification of the payload echoed in replies, comparing only its first depth bytes
+// (defaultVerifyDepth if depth <= 0) and checking it isn't truncated. Replies that fail are delivered with
+// Corrupt set; padding past the payload isn't a failure, see Proto.Padded. Checking a prefix keeps large
+// payloads cheap while still catching corrupted or mismatched replies. A
+// payload set with PayloadData is compared in full, and Echo Replies echoing a different one are delivered
+// with Corrupt set instead of being dropped as stray replies.
+func (tr *traceroute) VerifyPayload(depth int) {
+	if depth <= 0 {
+		depth = defaultVerifyDepth // Use the default depth.
+	}
+	tr.verify = depth
+}
+
+// GeoLookupFunc looks up the coordinates of an IP, e.g. in a MaxMind database, reporting ok if found.
+type GeoLookupFunc func(ip net.IP) (lat, lon float64, ok bool)
+
+// GeoLookup sets a hook annotating every reply with the coordinates of the replying hop. The package
+// ships no database; plug in any lookup, such as a MaxMind GeoLite2 reader.
+func (tr *traceroute) GeoLookup(lookup GeoLookupFunc) { tr.geo = lookup }
+
+// TOS marks every probe of the run with a Type-of-Service byte, or the traffic class for IPv6 targets, for
+// testing how a path treats QoS classes. The valid range is 0 to 255; the DSCP is the upper six bits, so
+// e.g. DSCP EF (46) is TOS 184. 0, the default, leaves the probes unmarked. Where the platform doesn't
+// support setting it, the probes are sent unmarked and the failure is logged when debugging.
+func (tr *traceroute) TOS(tos int) error {
+	if tos < 0 || tos > 255 {
+		return fmt.Errorf("icmpkg: TOS %d out of range 0-255", tos)
+	}
+	tr.tos = tos
+	return nil
+}
+
+// DontFragment sends IPv4 probes with the Don't Fragment bit set, so a hop whose next link is too small
+// for a probe drops it and answers with Fragmentation Needed, delivered as a Proto with FragNeeded and the
+// link's MTU set rather than as a timeout. Together with PayloadSize this allows path MTU discovery. IPv6
+// needs no option, as routers never fragment IPv6 and always answer oversized probes with Packet Too Big,
+// which is delivered the same way. Probes larger than the local interface's MTU fail to send and time
+// out. It's only supported on Linux; elsewhere Run panics as it does when the socket can't be opened.
+func (tr *traceroute) DontFragment(enabled bool) { tr.df = enabled }
+
+// KernelTimestamps measures RTTs up to the time the kernel received each reply, read from an
+// SO_TIMESTAMPNS control message, rather than the time the read loop got to it, so scheduling delays of
+// the process don't add jitter to the RTTs. The send time is taken just before the probe is written. The
+// kernel stamps replies with the wall clock rather than a monotonic one, so a clock step while a probe is
+// in flight skews its RTT. It's only supported on Linux with a raw socket; elsewhere replies are timed when
+// they are read.
+func (tr *traceroute) KernelTimestamps(enabled bool) { tr.rxts = enabled }
+
+// PcapFile records all sent and received ICMP packets to a pcap file at path for offline analysis.
+func (tr *traceroute) PcapFile(path string) { tr.pcapFile = path }
+
 // Run starts the traceroute or ping operation, ensuring it runs only once.
 func (tr *traceroute) Run() {
 	fn := func() {
-		tr.trace("Run() start")             // Log start of Run operation.
-		defer tr.trace("Run() end")         // Log end of Run operation.
-		tr.packet = newPacket(tr.rc, tr.wc) // Initialize packet handler.
-		go tr.startPong()                   // Start pong processing goroutine.
-		go tr.startHandler()                // Start handler goroutine.
-		go tr.startCtx()                    // Start context monitoring goroutine.
-		tr.runPing()                        // Run the ping or traceroute operation.
-		tr.Stop()                           // Stop the operation after completion.
+		tr.trace("Run() start")     // Log start of Run operation.
+		defer tr.trace("Run() end") // Log end of Run operation.
+		if tr.onResolve != nil {
+			tr.onResolve(tr.address, addrIP(tr.addr)) // Report the resolved IP.
+		}
+		if !tr.startPacket() {
+			tr.closeResults() // No handler goroutine will close the results channel.
+			return            // Stopped before it started.
+		}
+		defer tr.bg.Wait()   // Return once the goroutines exited, so every handler call finished.
+		tr.bg.Add(2)         // Track the pong and handler goroutines.
+		go tr.startPong()    // Start pong processing goroutine.
+		go tr.startHandler() // Start handler goroutine.
+		tr.startCtx()        // Start context monitoring goroutine.
+		if tr.deadline > 0 {
+			timer := time.AfterFunc(tr.deadline, func() { tr.stop(StatusDeadline) }) // Stop the operation when the deadline fires.
+			defer timer.Stop()
+		}
+		tr.runPing() // Run the ping or traceroute operation.
+		<-tr.handled // Let the handler goroutine drain the probes still queued.
+		if tr.unreached() {
+			tr.stop(StatusUnreached) // The trace gave up at the maximum TTL.
+		}
+		tr.stop(StatusCompleted) // Stop the operation after completion.
 	}
 	tr.runOnce.Do(fn) // Ensure Run is executed only once.
 }
 
-// Stop terminates the traceroute or ping operation, ensuring it stops only once.
-func (tr *traceroute) Stop() {
+// startPacket creates and starts the packet handler, unless the operation was stopped already. It holds
+// the hop map's mutex so that a concurrent stop sees either no packet handler or a running one.
+func (tr *traceroute) startPacket() bool {
+	tr.mu.Lock()         // Lock against a concurrent stop.
+	defer tr.mu.Unlock() // Unlock once the packet handler runs.
+	if tr.exited() {
+		return false
+	}
+	if tr.relay != nil {
+		c := newRelayClient(tr.relay, tr.relayHello(), tr.rc, tr.wc, tr.debug) // Forward probes to the agent.
+		c.run()
+		tr.packet = c
+		return true
+	}
+	pkt := newPacket(tr.rc, tr.wc) // Initialize packet handler.
+	pkt.pcapFile = tr.pcapFile     // Pass the pcap file, if any.
+	pkt.retries = tr.reconnects    // Pass the reconnect limit.
+	pkt.size = tr.size             // Pass the payload size.
+	pkt.data = tr.data             // Pass the explicit payload replies must echo, if any.
+	pkt.verify = tr.verify         // Pass the payload verification depth.
+	pkt.bpf = tr.bpf               // Pass the socket filter option.
+	pkt.v6 = tr.v6                 // Speak ICMPv6 to IPv6 targets.
+	pkt.tos = tr.tos               // Mark the probes, if enabled.
+	pkt.df = tr.df                 // Set the Don't Fragment bit, if enabled.
+	pkt.rxts = tr.rxts             // Time replies on arrival, if enabled.
+	pkt.maxAge = tr.maxAge()       // Forget probes that timed out long ago.
+	if tr.v6 {
+		pkt.listenAddr = listenAddress6 // Listen on all IPv6 addresses by default.
+	}
+	if tr.source != nil {
+		pkt.listenAddr = tr.source.String() // Bind to the chosen interface's address.
+	}
+	if tr.traceroute {
+		pkt.src = tr.source // Detect NAT against our source address.
+		if pkt.src == nil {
+			pkt.src = sourceIP(tr.addr) // Use the address the kernel picks for the target.
+		}
+	}
+	pkt.label(tagPrefix(tr.tag), tr.mode()+":"+tr.address) // Attribute the packet layer's logs to the operation.
+	pkt.run()                                              // Start packet handler.
+	tr.packet = pkt
+	return true
+}
+
+// relayHello returns the configuration of the agent's packet handler, mirroring startPacket.
+func (tr *traceroute) relayHello() relayHello {
+	return relayHello{Target: addrIP(tr.addr).String(), V6: tr.v6, Traceroute: tr.traceroute, Size: tr.size, Verify: tr.verify, Data: tr.data, BPF: tr.bpf, TOS: tr.tos, DF: tr.df, RxTS: tr.rxts, MaxAge: tr.maxAge()}
+}
+
+// maxAge returns how long the packet handler keeps unanswered probes: twice as long as a reply is
+// awaited, leaving time to report a late reply as OutOfOrder.
+func (tr *traceroute) maxAge() time.Duration { return 2 * (tr.readDur + tr.lateGrace) }
+
+// Stop terminates the traceroute or ping operation, ensuring it stops only once. Run returns once the
+// probes in flight were abandoned and the handlers running have returned. Stop may be called from any
+// goroutine, also before Run, which then returns at once.
+func (tr *traceroute) Stop() { tr.stop(StatusStopped) }
+
+// exited reports whether the operation was stopped.
+func (tr *traceroute) exited() bool {
+	select {
+	case <-tr.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// send delivers pto on c unless the operation stops first, so senders never block on a consumer that
+// went away. It reports whether pto was delivered.
+func (tr *traceroute) send(c chan<- *Proto, pto *Proto) bool {
+	select {
+	case c <- pto:
+		return true
+	case <-tr.done:
+		return false
+	}
+}
+
+// sleep waits for d, returning early if the operation stops.
+func (tr *traceroute) sleep(d time.Duration) {
+	if d <= 0 {
+		return // Never sleep for a negative duration.
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-tr.done:
+	}
+}
+
+// Pause holds back every probe not yet sent until Resume is called, keeping the socket open and still
+// handling the replies and timeouts of probes already sent. Probing then continues where it left off, so
+// an interactive tool can freeze its measurements without tearing the operation down. Stop, the context
+// and Deadline still end a paused operation. Pausing before Run holds back even the first probe.
+func (tr *traceroute) Pause() {
+	tr.pauseMu.Lock()
+	defer tr.pauseMu.Unlock()
+	if tr.resume == nil {
+		tr.resume = make(chan struct{}) // Closed by Resume to release the held probes.
+	}
+}
+
+// Resume lets the probes held back by Pause be sent. It does nothing if the operation isn't paused.
+func (tr *traceroute) Resume() {
+	tr.pauseMu.Lock()
+	defer tr.pauseMu.Unlock()
+	if tr.resume != nil {
+		close(tr.resume)
+		tr.resume = nil
+	}
+}
+
+// Paused reports whether the operation is paused, see Pause.
+func (tr *traceroute) Paused() bool {
+	tr.pauseMu.Lock()
+	defer tr.pauseMu.Unlock()
+	return tr.resume != nil
+}
+
+// gate blocks while the operation is paused, until it is resumed or stopped.
+func (tr *traceroute) gate() {
+	tr.pauseMu.Lock()
+	resume := tr.resume
+	tr.pauseMu.Unlock()
+	if resume == nil {
+		return // Not paused.
+	}
+	tr.trace("gate() paused")
+	select {
+	case <-resume:
+	case <-tr.done:
+	}
+}
+
+// hops returns the number of hops to probe, lowered once the destination replied.
+func (tr *traceroute) hops() int { return int(atomic.LoadInt32(&tr.maxHop)) }
+
+// Status returns how the operation ended, or StatusNone while it hasn't finished.
+func (tr *traceroute) Status() RunStatus { return RunStatus(atomic.LoadInt32(&tr.status)) }
+
+// stop terminates the operation once, recording status as the reason it ended.
+func (tr *traceroute) stop(status RunStatus) {
 	fn := func() {
-		tr.trace("Stop() start")     // Log start of Stop operation.
-		defer tr.trace("Stop() end") // Log end of Stop operation.
-		tr.exit = true               // Set exit flag.
-		if tr.packet != nil {
-			tr.packet.stop() // Stop the packet handler.
+		tr.trace("Stop() start")                     // Log start of Stop operation.
+		defer tr.trace("Stop() end")                 // Log end of Stop operation.
+		atomic.StoreInt32(&tr.status, int32(status)) // Record why the operation ended.
+		close(tr.done)                               // Wake the goroutines blocked on the operation.
+		tr.mu.Lock()                                 // Lock against a concurrent start.
+		pkt := tr.packet
+		tr.mu.Unlock()
+		if pkt != nil {
+			pkt.stop() // Stop the packet handler.
 		}
 		tr.pec <- struct{}{}          // Signal pong goroutine to exit.
 		close(tr.pec)                 // Close pong exit channel.
@@ -169,7 +793,6 @@ func (tr *traceroute) Stop() {
 			close(tr.cec)                 // Close context exit channel.
 			tr.trace("Stop() closed cec") // Log context channel closure.
 		}
-		tr.closes() // Close all per-TTL channels.
 	}
 	tr.stopOnce.Do(fn) // Ensure Stop is executed only once.
 }
@@ -178,29 +801,75 @@ func (tr *traceroute) Stop() {
 func (tr *traceroute) pong(pto *Proto) {
 	tr.trace("pong() start")     // Log start of pong processing.
 	defer tr.trace("pong() end") // Log end of pong processing.
-	ttl := pto.TTL
-	if tr.traceroute {
-		ttl-- // Adjust TTL index for traceroute mode.
+	ttl, ok := tr.hopOf(pto.ID)  // Route by the echoed ID, which reliably identifies the hop.
+	if !ok {
+		tr.debug("pong() unknown id: %d", pto.ID)
+		return // Drop replies for IDs we never allocated.
+	}
+	// Never block: once a TTL finished probing nobody reads its channel, and late replies to its timed-out
+	// probes would stall every other hop's replies behind them.
+	for {
+		select {
+		case tr.ic[ttl] <- pto:
+			return // Send Proto to the corresponding TTL channel.
+		default:
+		}
+		if pto.Dup {
+			tr.debug("pong() dropped duplicate, no probe waiting: %s", pto)
+			return // A duplicate never displaces another reply.
+		}
+		select {
+		case old := <-tr.ic[ttl]:
+			tr.debug("pong() dropped unread reply, no probe waiting: %s", old) // Make room for the newer reply.
+		default:
+		}
 	}
-	tr.ic[ttl] <- pto // Send Proto to the corresponding TTL channel.
+}
+
+// setHop records the TTL index that owns an ICMP ID.
+func (tr *traceroute) setHop(id, ttl int) {
+	tr.mu.Lock()         // Lock for thread-safe map access.
+	defer tr.mu.Unlock() // Unlock after map access.
+	tr.hop[id] = ttl
+}
+
+// hopOf returns the TTL index that owns an ICMP ID.
+func (tr *traceroute) hopOf(id int) (ttl int, ok bool) {
+	tr.mu.Lock()         // Lock for thread-safe map access.
+	defer tr.mu.Unlock() // Unlock after map access.
+	ttl, ok = tr.hop[id]
+	return
 }
 
 // startPong runs a goroutine to process incoming Proto messages from the read channel.
 func (tr *traceroute) startPong() {
 	tr.trace("startPong() start")     // Log start of pong goroutine.
 	defer tr.trace("startPong() end") // Log end of pong goroutine.
+	defer tr.bg.Done()                // Signal background goroutine completion.
 	for {
 		select {
 		case <-tr.pec:
 			return // Exit if pong exit channel is signaled.
 		case pto, ok := <-tr.rc:
 			if !ok {
+				if !tr.exited() {
+					tr.bg.Add(1) // Track the stopping goroutine.
+					go func() {
+						defer tr.bg.Done()
+						tr.stop(StatusError) // The packet layer went away on its own; abort the run.
+					}()
+				}
 				return // Exit if read channel is closed.
 			}
 			tr.debug("packet->>>>>>: %s", pto.String()) // Log received Proto message.
-			if tr.traceroute && pto.Ip4 == tr.ip4 && tr.maxHop > pto.TTL {
-				tr.trace("found max hop: %d", pto.TTL) // Update max hop if destination reached.
-				tr.maxHop = pto.TTL
+			if tr.traceroute && (pto.Ip4 == tr.ip4 || pto.Unreachable) {
+				if pto.Ip4 == tr.ip4 {
+					atomic.StoreInt32(&tr.reached, 1) // Record that the destination replied.
+				}
+				if tr.hops() > pto.TTL { // Like traceroute(8), don't probe past a hop rejecting the probe.
+					tr.trace("found max hop: %d", pto.TTL) // Update max hop if destination reached.
+					atomic.StoreInt32(&tr.maxHop, int32(pto.TTL))
+				}
 			}
 			tr.pong(pto) // Process the Proto message.
 		}
@@ -209,17 +878,46 @@ func (tr *traceroute) startPong() {
 
 // handler forwards a Proto message to the handler channel and invokes the pong handler.
 func (tr *traceroute) handler(pto *Proto) {
-	if tr.exit {
+	if tr.exited() {
 		return // Skip if operation is terminated.
 	}
-	tr.hc <- pto                       // Send Proto to handler channel.
-	tr.debug("handler<<<<<-: %s", pto) // Log handled Proto message.
+	pto.Tag = tr.tag  // Carry the run ID.
+	pto.IsV6 = tr.v6  // Carry the address family, also for timeouts.
+	tr.annotate(pto)  // Annotate the probe before it is recorded or handled.
+	tr.stats.add(pto) // Account for the probe in the statistics.
+	if tr.report != nil {
+		tr.report.add(pto) // Record the probe for the report.
+	}
+	if tr.send(tr.hc, pto) {
+		tr.debug("handler<<<<<-: %s", pto) // Log handled Proto message.
+	}
+}
+
+// annotate enriches a finished probe with the optional per-hop information configured on the operation.
+func (tr *traceroute) annotate(pto *Proto) {
+	if pto.IsTimeout() {
+		return // Timeouts have no replying hop to annotate.
+	}
+	ip := net.ParseIP(pto.Ip4)
+	if ip == nil {
+		return // Nothing to annotate without the hop's IP.
+	}
+	pto.Private = IsBogon(ip) // Flag private and bogon hops.
+	if tr.names != nil {
+		pto.Host = tr.names.name(pto.Ip4) // Name the hop once its lookup finished.
+	}
+	if tr.geo != nil {
+		pto.Lat, pto.Lon, pto.Geo = tr.geo(ip) // Look up the hop's coordinates.
+	}
 }
 
 // startHandler runs a goroutine to process Proto messages from the handler channel.
 func (tr *traceroute) startHandler() {
 	tr.trace("startHandler() start")     // Log start of handler goroutine.
 	defer tr.trace("startHandler() end") // Log end of handler goroutine.
+	defer tr.bg.Done()                   // Signal background goroutine completion.
+	defer close(tr.handled)              // Let Run return once no more handlers are invoked.
+	defer tr.closeResults()              // Close the results channel once nothing is delivered anymore.
 	for {
 		select {
 		case <-tr.hec:
@@ -228,40 +926,72 @@ func (tr *traceroute) startHandler() {
 			if !ok {
 				return // Exit if handler channel is closed.
 			}
-			if tr.pongHandler != nil && pto != nil {
-				tr.pongHandler(pto) // Invoke pong handler callback if set.
+			if pto == nil {
+				continue // Skip empty messages.
+			}
+			if tr.handlerTimeout <= 0 {
+				tr.handle(pto)  // Invoke the handlers inline.
+				tr.deliver(pto) // Deliver the Proto to the results channel.
+				continue
+			}
+			done := make(chan struct{})
+			go func() {
+				defer close(done) // Signal handler completion.
+				tr.handle(pto)    // Invoke the handlers in the background.
+			}()
+			select {
+			case <-done:
+			case <-time.After(tr.handlerTimeout):
+				atomic.AddInt32(&tr.handlerTimeouts, 1)                                    // Count the stalled handler.
+				tr.debug("startHandler() handler exceeded %v: %s", tr.handlerTimeout, pto) // Log and move on.
+			case <-tr.hec:
+				return // Exit if handler exit channel is signaled.
 			}
+			tr.deliver(pto) // Deliver the Proto to the results channel.
 		}
 	}
 }
 
-// closes closes all per-TTL Proto channels.
-func (tr *traceroute) closes() {
-	for ttl, ic := range tr.ic {
-		if ic != nil {
-			close(ic) // Close the TTL-specific channel.
-			if tr.traceroute {
-				tr.trace("closes() closed ic ttl: %d", ttl+1) // Log closure in traceroute mode.
-			} else {
-				tr.trace("closes() closed ic") // Log closure in ping mode.
-			}
+// deliver sends pto to the results channel, if any, unless the operation stops first. Only the handler
+// goroutine delivers, so the channel is never sent on once it closed.
+func (tr *traceroute) deliver(pto *Proto) {
+	if tr.results != nil {
+		tr.send(tr.results, pto)
+	}
+}
+
+// closeResults closes the results channel, if any.
+func (tr *traceroute) closeResults() {
+	if tr.results != nil {
+		close(tr.results)
+	}
+}
+
+// handle invokes the probe hooks and the pong handler for a finished probe.
+func (tr *traceroute) handle(pto *Proto) {
+	for _, hook := range tr.probeHooks {
+		if !pto.Unreached {
+			hook(pto) // Invoke probe hooks for actual probes.
 		}
 	}
+	if tr.pongHandler != nil {
+		tr.pongHandler(pto) // Invoke pong handler callback if set.
+	}
 }
 
 // ping sends a Proto message to the write channel for transmission.
 func (tr *traceroute) ping(pto *Proto) {
-	if tr.exit {
-		return // Skip if operation is terminated.
+	tr.gate() // Hold the probe while paused.
+	if tr.send(tr.wc, pto) {
+		tr.debug("packet<<<<<<-: %s", pto) // Log sent Proto message.
 	}
-	tr.wc <- pto                       // Send Proto to write channel.
-	tr.debug("packet<<<<<<-: %s", pto) // Log sent Proto message.
 }
 
 // runPing executes the ping or traceroute operation for each TTL.
 func (tr *traceroute) runPing() {
 	tr.trace("runPing() start")     // Log start of runPing operation.
 	defer tr.trace("runPing() end") // Log end of runPing operation.
+	tr.epoch = time.Now()           // Reference for interleaving slots.
 
 	closes := func() {
 		close(tr.wc)                    // Close write channel.
@@ -270,34 +1000,281 @@ func (tr *traceroute) runPing() {
 		tr.trace("runPing() closed hc") // Log handler channel closure.
 	}
 
-	for ttl := 0; ttl < tr.maxHop; ttl++ {
-		if tr.id[ttl] == 0 {
-			tr.id[ttl] = int(nextIcmpId())    // Assign a new ICMP ID for the TTL.
-			tr.ic[ttl] = make(chan *Proto, 1) // Initialize Proto channel for the TTL.
-		}
-		id := tr.id[ttl]
+	if tr.uniform {
+		tr.runUniform() // Probe all TTLs at once, handling the first probes like the rest.
+	} else if tr.traceroute && tr.parallel && tr.count > 0 {
+		tr.runParallel() // Probe all TTLs at once.
+	} else {
+		tr.runSerial() // Probe TTL after TTL.
+	}
+	tr.wg.Wait() // Wait for all TTL goroutines to complete.
+	if tr.notifyUnreached && tr.unreached() {
+		tr.send(tr.hc, tr.unreachedProto()) // Emit the final event.
+	}
+	closes() // Close channels once no goroutine sends on them anymore.
+}
+
+// runSerial probes TTL after TTL, waiting for the first probe of each before probing the next, and starts
+// a goroutine for the remaining probes of every TTL.
+func (tr *traceroute) runSerial() {
+	lost := 0 // Consecutive hops whose first probe went unanswered.
+	for ttl := 0; ttl < tr.hops(); ttl++ {
+		tr.allocate(ttl)
 		ttl0 := ttl
 		if tr.traceroute {
 			ttl0++ // Adjust TTL for traceroute mode.
 		}
-		if tr.exit {
-			closes() // Close channels if operation is terminated.
-			return
+		if tr.exited() {
+			break // Stop probing further hops; the TTL goroutines still hold the channels.
 		}
-		tr.ping(pingProto(ttl0, id, 0, tr.addr, tr.ip4)) // Send initial ping for the TTL.
-		tr.handler(tr.readTTL(ttl, id, 0))               // Process response for initial ping.
-		tr.wg.Add(1)                                     // Increment WaitGroup for TTL goroutine.
-		go tr.runTTL(ttl, tr.count)                      // Start goroutine for remaining pings in TTL.
+		pto := tr.first(ttl, tr.handler) // Send the initial ping for the TTL and wait for the response.
+		tr.handler(pto)                  // Process response for initial ping.
+		tr.countReply(ttl, pto)          // Count the reply, if any.
 		if !tr.traceroute {
-			break // Exit loop after first TTL in ping mode.
+			tr.wg.Add(1)                            // Increment WaitGroup for the ping goroutine.
+			go tr.runTTL(ttl, tr.count, tr.handler) // Start goroutine for remaining pings.
+			break                                   // Exit loop after first TTL in ping mode.
+		}
+		if tr.budget <= 0 {
+			tr.wg.Add(1)                            // Increment WaitGroup for TTL goroutine.
+			go tr.runTTL(ttl, tr.count, tr.handler) // Start goroutine for remaining pings in TTL.
+		}
+		if pto.IsTimeout() {
+			lost++
+		} else {
+			lost = 0 // The hop answered; start counting afresh.
+		}
+		if tr.giveUp > 0 && lost >= tr.giveUp {
+			tr.debug("runSerial() giving up after %d unanswered hops at ttl %d", lost, ttl0)
+			atomic.StoreInt32(&tr.maxHop, int32(ttl+1)) // Spend a probe budget on the probed hops only.
+			break
 		}
 	}
-	tr.wg.Wait() // Wait for all TTL goroutines to complete.
-	closes()     // Close channels after completion.
+	if tr.traceroute && tr.budget > 0 && !tr.exited() {
+		tr.runBudget() // Spend the probe budget once the path length is known.
+	}
+}
+
+// unreachedProto creates the final event of a traceroute that never reached the destination.
+func (tr *traceroute) unreachedProto() *Proto {
+	return &Proto{TTL: tr.maxTTL, Addr: tr.addr, Ip4: tr.ip4, Time: time.Now(), Unreached: true, Tag: tr.tag, IsV6: tr.v6}
+}
+
+// allocate assigns an ICMP ID and a reply channel to a TTL index, unless it has them already.
+func (tr *traceroute) allocate(ttl int) {
+	if tr.id[ttl] != 0 {
+		return // Keep the ID across probes of the TTL.
+	}
+	tr.id[ttl] = int(nextIcmpId())    // Assign a new ICMP ID for the TTL.
+	tr.ic[ttl] = make(chan *Proto, 1) // Initialize Proto channel for the TTL.
+	tr.setHop(tr.id[ttl], ttl)        // Route replies carrying this ID to the TTL.
+	tr.packet.own(tr.id[ttl])         // Only accept replies carrying our own IDs.
 }
 
-// runTTL sends additional pings for a specific TTL and processes responses.
-func (tr *traceroute) runTTL(ttl, count int) {
+// first sends the initial probe of a TTL index and waits for its reply or timeout, passing duplicate and
+// out-of-order replies read meanwhile to handle.
+func (tr *traceroute) first(ttl int, handle func(pto *Proto)) *Proto {
+	ttl0 := ttl
+	if tr.traceroute {
+		ttl0++ // Adjust TTL for traceroute mode.
+	}
+	tr.sent[ttl] = time.Now()                     // Pace the following probes from here.
+	tr.started[ttl] = tr.sent[ttl]                // Schedule replies for ReplyRate from here.
+	tr.ping(tr.probe(ttl0, tr.id[ttl], 0))        // Send initial ping for the TTL.
+	return tr.readTTL(ttl, tr.id[ttl], 0, handle) // Wait for the response to the initial ping.
+}
+
+// runParallel probes all TTLs concurrently, each in its own goroutine, and hands the results to the
+// handler in TTL order. Each TTL queues its results, duplicate and out-of-order replies included, until
+// the handler got to it, so the probes never wait for the handler.
+func (tr *traceroute) runParallel() {
+	counts := make([]int, tr.maxTTL)
+	for ttl := range counts {
+		counts[ttl] = tr.count // Probe every TTL count times.
+	}
+	if tr.budget > 0 {
+		counts = budgetCounts(tr.budget, tr.maxTTL) // The path length isn't known up front.
+	}
+	queues := make([]*hopQueue, tr.maxTTL)
+	for ttl := range queues {
+		tr.allocate(ttl)
+		queues[ttl] = newHopQueue()                 // Hold every result of the TTL.
+		tr.wg.Add(1)                                // Increment WaitGroup for TTL goroutine.
+		go tr.runHop(ttl, counts[ttl], queues[ttl]) // Start goroutine for all pings in TTL.
+	}
+	for ttl, queue := range queues {
+		for pto, ok := queue.pop(); ok; pto, ok = queue.pop() {
+			if ttl < tr.hops() {
+				tr.handler(pto) // Process responses of hops up to the destination.
+			}
+		}
+	}
+}
+
+// runUniform probes all TTLs concurrently, each in its own goroutine sending every probe of the TTL, the
+// first included, and handing the results to the handler as they arrive.
+func (tr *traceroute) runUniform() {
+	counts := make([]int, tr.maxTTL)
+	for ttl := range counts {
+		counts[ttl] = tr.count // Probe every TTL count times.
+	}
+	if tr.traceroute && tr.budget > 0 {
+		counts = budgetCounts(tr.budget, tr.maxTTL) // The path length isn't known up front.
+	}
+	for ttl, count := range counts {
+		tr.allocate(ttl)
+		tr.wg.Add(1) // Increment WaitGroup for TTL goroutine.
+		go func(ttl, count int) {
+			handle := func(pto *Proto) {
+				if ttl < tr.hops() {
+					tr.handler(pto) // Process responses of hops up to the destination.
+				}
+			}
+			if ttl >= tr.hops() || tr.exited() {
+				tr.wg.Done() // The destination replied from a nearer hop before this one started.
+				return
+			}
+			pto := tr.first(ttl, handle) // Send the initial ping for the TTL and wait for the response.
+			handle(pto)                  // Process response for initial ping.
+			tr.countReply(ttl, pto)      // Count the reply, if any.
+			tr.runTTL(ttl, count, handle)
+		}(ttl, count)
+	}
+}
+
+// runHop sends all pings for a specific TTL, queuing the responses, and closes the queue when done.
+func (tr *traceroute) runHop(ttl, count int, queue *hopQueue) {
+	defer queue.close()              // Let runParallel move on to the next TTL.
+	pto := tr.first(ttl, queue.push) // Send the initial ping for the TTL and wait for the response.
+	queue.push(pto)                  // Queue the response for the handler.
+	tr.countReply(ttl, pto)          // Count the reply, if any.
+	tr.runTTL(ttl, count, queue.push)
+}
+
+// hopQueue is an unbounded FIFO of the results of a TTL, as duplicate and out-of-order replies make their
+// number unknown up front.
+type hopQueue struct {
+	mu     *sync.Mutex // Mutex for thread-safe access to the queue.
+	cond   *sync.Cond  // Signals pushes and the close.
+	ptos   []*Proto    // Queued results.
+	closed bool        // Whether no more results are pushed.
+}
+
+// newHopQueue creates an empty queue.
+func newHopQueue() *hopQueue {
+	mu := &sync.Mutex{}
+	return &hopQueue{mu: mu, cond: sync.NewCond(mu)}
+}
+
+// push appends a result to the queue without blocking.
+func (q *hopQueue) push(pto *Proto) {
+	q.mu.Lock()
+	q.ptos = append(q.ptos, pto)
+	q.mu.Unlock()
+	q.cond.Signal() // Wake the reader.
+}
+
+// close marks the queue as complete; pop returns the queued results, then reports it is drained.
+func (q *hopQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal() // Wake the reader.
+}
+
+// pop removes and returns the oldest result, waiting for one; ok is false once the queue is drained
+// after close.
+func (q *hopQueue) pop() (pto *Proto, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.ptos) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.ptos) == 0 {
+		return nil, false
+	}
+	pto, q.ptos = q.ptos[0], q.ptos[1:]
+	return pto, true
+}
+
+// unreached reports whether a traceroute finished without the destination ever replying.
+func (tr *traceroute) unreached() bool {
+	return tr.traceroute && !tr.exited() && atomic.LoadInt32(&tr.reached) == 0
+}
+
+// runBudget starts the per-TTL goroutines with probe counts weighted by TTL within the probe budget.
+func (tr *traceroute) runBudget() {
+	for ttl, count := range budgetCounts(tr.budget, tr.hops()) {
+		tr.wg.Add(1)                         // Increment WaitGroup for TTL goroutine.
+		go tr.runTTL(ttl, count, tr.handler) // Start goroutine for remaining pings in TTL.
+	}
+}
+
+// budgetCounts splits a total probe budget across hops, giving every hop at least one probe and weighting
+// the rest by TTL so that distant hops, which vary the most, receive the most samples.
+func budgetCounts(total, hops int) []int {
+	counts := make([]int, hops)
+	if hops <= 0 {
+		return counts
+	}
+	extra := total - hops // Probes left after the initial probe of every hop.
+	if extra < 0 {
+		extra = 0
+	}
+	weights := hops * (hops + 1) / 2 // Sum of TTLs 1..hops.
+	spent := 0
+	for ttl := range counts {
+		n := extra * (ttl + 1) / weights
+		counts[ttl] = 1 + n
+		spent += n
+	}
+	for ttl := hops - 1; spent < extra; ttl-- {
+		counts[ttl]++ // Hand rounding leftovers to the farthest hops.
+		spent++
+		if ttl == 0 {
+			ttl = hops
+		}
+	}
+	return counts
+}
+
+// probe creates the Proto of an Echo Request to the target, carrying the configured payload.
+func (tr *traceroute) probe(ttl, id, seq int) *Proto {
+	pto := pingProto(ttl, id, tr.seqStart+seq, tr.addr, tr.ip4)
+	pto.data = payload(tr.size, tr.seqStart+seq) // Attach the payload, if any, patterned on the wire seq.
+	pto.IsV6 = tr.v6                             // Send an ICMPv6 Echo Request to IPv6 targets.
+	if tr.data != nil {
+		pto.data = tr.data // Send the explicit payload instead.
+	}
+	return pto
+}
+
+// slotDelay returns how long to wait from now until the interleaving slot of a TTL index. Each pacing
+// period is divided evenly among maxTTL slots, relative to the start of the run.
+func (tr *traceroute) slotDelay(ttl int, now time.Time) time.Duration {
+	pace := tr.pace()
+	if pace <= 0 || tr.maxTTL <= 0 {
+		return 0 // Nothing to interleave.
+	}
+	slot := pace * time.Duration(ttl) / time.Duration(tr.maxTTL) // Offset of the TTL's slot.
+	d := slot - now.Sub(tr.epoch)%pace
+	if d < 0 {
+		d += pace // The slot already passed in this period; take the next one.
+	}
+	return d
+}
+
+// ttlCount returns the number of probes to send to a TTL index, count unless it is the hop the destination
+// answered at and DestinationCount overrides it.
+func (tr *traceroute) ttlCount(ttl, count int) int {
+	if tr.destCount > 0 && tr.traceroute && atomic.LoadInt32(&tr.reached) == 1 && ttl == tr.hops()-1 {
+		return tr.destCount // Sample the destination more, or less, than the hops before it.
+	}
+	return count
+}
+
+// runTTL sends additional pings for a specific TTL and passes the responses to handle.
+func (tr *traceroute) runTTL(ttl, count int, handle func(pto *Proto)) {
 	ttl0 := ttl
 	if tr.traceroute {
 		ttl0++ // Adjust TTL for traceroute mode.
@@ -305,17 +1282,64 @@ func (tr *traceroute) runTTL(ttl, count int) {
 	tr.trace("runTTL() start ttl: %d count: %d", ttl0, count)     // Log start of runTTL.
 	defer tr.trace("runTTL() end ttl: %d count: %d", ttl0, count) // Log end of runTTL.
 	defer tr.wg.Done()                                            // Signal WaitGroup completion.
-	for seq := 1; seq < count; seq++ {
-		if tr.exit {
+	// Probe until stopped if count <= 0.
+	for seq := 1; count <= 0 || seq < tr.ttlCount(ttl, count); seq++ {
+		if tr.replyRate > 0 {
+			tr.sleep(tr.rateDelay(ttl, time.Now())) // Wait until the next reply is due.
+		} else if tr.interleave && tr.traceroute {
+			tr.sleep(tr.slotDelay(ttl, time.Now())) // Wait for the TTL's slot in the next pacing period.
+		} else {
+			tr.wait(ttl) // Wait out the pacing period since the previous probe.
+		}
+		if tr.exited() {
 			return // Exit if operation is terminated.
 		}
-		tr.ping(pingProto(ttl0, tr.id[ttl], seq, tr.addr, tr.ip4)) // Send ping for sequence.
-		tr.handler(tr.readTTL(ttl, tr.id[ttl], seq))               // Process response.
+		if (tr.parallel || tr.uniform) && ttl >= tr.hops() {
+			return // The destination replied from a nearer hop; this one lies past it.
+		}
+		tr.sent[ttl] = time.Now()                       // Record the send time for pacing.
+		tr.ping(tr.probe(ttl0, tr.id[ttl], seq))        // Send ping for sequence.
+		pto := tr.readTTL(ttl, tr.id[ttl], seq, handle) // Wait for the response.
+		handle(pto)                                     // Process response.
+		tr.countReply(ttl, pto)                         // Count the reply, if any.
+	}
+}
+
+// countReply records a reply of a TTL for ReplyRate. Each TTL is counted by a single goroutine at a time.
+func (tr *traceroute) countReply(ttl int, pto *Proto) {
+	if pto != nil && !pto.IsTimeout() {
+		tr.replies[ttl]++ // Nothing to count once the operation stopped.
 	}
 }
 
+// rateDelay returns how long to wait from now before the next probe of a TTL under ReplyRate. Replies are
+// scheduled one period apart from the TTL's first send time, so after n replies the next probe is due n
+// periods after it, and a TTL that fell behind is due at once.
+func (tr *traceroute) rateDelay(ttl int, now time.Time) time.Duration {
+	period := time.Duration(float64(time.Second) / tr.replyRate)
+	due := tr.started[ttl].Add(period * time.Duration(tr.replies[ttl]))
+	if d := due.Sub(now); d > 0 {
+		return d
+	}
+	return 0 // Behind schedule; probe again at once to catch up.
+}
+
+// pace returns the time between successive probes of the same TTL. Probes of a TTL are sent one at a
+// time, so a probe whose reply or timeout takes longer than the pace delays the next one.
+func (tr *traceroute) pace() time.Duration {
+	if tr.interval > 0 {
+		return tr.interval // Use the configured interval.
+	}
+	return tr.readDur // Default to the read duration.
+}
+
+// wait sleeps until the pace has elapsed since the latest probe of a TTL, returning at once if it has.
+func (tr *traceroute) wait(ttl int) {
+	tr.sleep(tr.pace() - time.Since(tr.sent[ttl])) // Only sleep for the remainder, if any.
+}
+
 // readTTL waits for a response for a specific TTL, ID, and sequence number, handling timeouts.
-func (tr *traceroute) readTTL(ttl, id, seq int) (pto *Proto) {
+func (tr *traceroute) readTTL(ttl, id, seq int, handle func(pto *Proto)) (pto *Proto) {
 	now := time.Now()
 	ttl0 := ttl
 	if tr.traceroute {
@@ -323,15 +1347,37 @@ func (tr *traceroute) readTTL(ttl, id, seq int) (pto *Proto) {
 	}
 	tr.trace("readTTL() start ttl: %d id: %d seq: %d", ttl0, id, seq)     // Log start of readTTL.
 	defer tr.trace("readTTL() end ttl: %d id: %d seq: %d", ttl0, id, seq) // Log end of readTTL.
+	timer := time.NewTimer(tr.readDur)
+	defer timer.Stop()
+	late := false // Whether the read timeout passed and the grace window is running.
 	for {
 		select {
 		case pto = <-tr.ic[ttl]:
-			if seq > 0 {
-				time.Sleep(tr.readDur - time.Since(now)) // Adjust timing for subsequent pings.
+			if pto.Dup {
+				handle(pto) // Report a duplicate as it arrives, whichever probe it answers.
+				continue
+			}
+			if pto.Seq != tr.seqStart+seq {
+				tr.debug("readTTL() out of order reply: %s", pto)
+				pto.OutOfOrder = true // A reply to an earlier probe that timed out, lower than the seqs handled.
+				handle(pto)
+				continue // Keep waiting for ours.
 			}
-			return // Return received Proto message.
-		case <-time.After(tr.readDur):
-			pto = timeoutProto(ttl0, id, seq)                                   // Create timeout Proto on read timeout.
+			pto.Late = late // Credit a reply within the grace window as late.
+			return          // Return received Proto message.
+		case <-tr.done:
+			pto = timeoutProto(ttl0, id, tr.seqStart+seq) // Abandon the probe once the operation stops.
+			pto.Sent, pto.Time = now, time.Now()
+			return
+		case <-timer.C:
+			if !late && tr.lateGrace > 0 {
+				late = true               // Keep waiting for a late reply.
+				timer.Reset(tr.lateGrace) // Until the grace window passes.
+				continue
+			}
+			pto = timeoutProto(ttl0, id, tr.seqStart+seq)                       // Create timeout Proto on read timeout.
+			pto.Sent, pto.Time = now, time.Now()                                // Record wait start and timeout times.
+			pto.sentBytes = 8 + tr.size                                         // ICMP header and payload, as written for the probe.
 			tr.trace("readTTL() timeout ttl: %d id: %d seq: %d", ttl0, id, seq) // Log timeout.
 			tr.debug("timeout->>>>>: %s", pto)                                  // Log timeout Proto.
 			return                                                              // Return timeout Proto.
@@ -346,13 +1392,15 @@ func (tr *traceroute) startCtx() {
 	}
 	tr.trace("startCtx() start")     // Log start of context monitoring.
 	defer tr.trace("startCtx() end") // Log end of context monitoring.
+	tr.bg.Add(1)                     // Track the context goroutine.
 	go func() {
+		defer tr.bg.Done() // Signal background goroutine completion.
 		for {
 			select {
 			case <-tr.cec:
 				return // Exit if context exit channel is signaled.
 			case <-tr.ctx.Done():
-				tr.Stop() // Stop operation on context cancellation.
+				tr.stop(StatusCancelled) // Stop operation on context cancellation.
 				return
 			}
 		}
@@ -362,20 +1410,67 @@ func (tr *traceroute) startCtx() {
 // ip4 resolves an address to an IPv4 net.Addr and its string representation.
 func ip4(s string) (net.Addr, string) {
 	if ip := net.ParseIP(s); ip != nil {
-		return &net.IPAddr{IP: ip}, s // Return parsed IP address if valid.
+		addr := &net.IPAddr{IP: ip}
+		return addr, aip4(addr) // Return parsed IP address if valid, unmapping v4-mapped IPv6 forms.
 	}
 	addr, _ := net.ResolveIPAddr("ip4", s) // Resolve address to IPv4.
 	return addr, aip4(addr)                // Return resolved address and its string form.
 }
 
+// ip6 resolves an address to an IPv6 net.Addr and its string representation.
+func ip6(s string) (net.Addr, string) {
+	addr, _ := net.ResolveIPAddr("ip6", s) // Resolve address to IPv6.
+	return addr, aip4(addr)                // Return resolved address and its string form.
+}
+
+// isV6 reports whether a resolved address is an IPv6 address, rather than IPv4 or v4-mapped IPv6.
+func isV6(a net.Addr) bool {
+	ip := addrIP(a)
+	return ip != nil && ip.To4() == nil
+}
+
 // aip4 converts a net.Addr to its IPv4 string representation.
 func aip4(a net.Addr) (ip4 string) {
 	if a == nil {
 		return // Return empty string if address is nil.
 	}
-	ipa, ok := a.(*net.IPAddr)
-	if !ok || ipa == nil {
-		return // Return empty string if not an IPAddr or nil.
+	ip := addrIP(a)
+	if ip == nil {
+		return // Return empty string if the address carries no IP.
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() // Return IPv4 address as string, also for v4-mapped IPv6 addresses.
+	}
+	return ip.String() // Return other addresses as is.
+}
+
+// sourceIP returns the local address the kernel picks to reach addr, or nil if there is no route. The UDP
+// socket is only connected, so nothing is sent.
+func sourceIP(addr net.Addr) net.IP {
+	ip := addrIP(addr)
+	if ip == nil {
+		return nil
+	}
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: 9})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+// addrIP extracts the IP from a net.Addr, returning nil for unsupported address types. Raw sockets
+// report *net.IPAddr sources, while unprivileged datagram sockets report *net.UDPAddr.
+func addrIP(a net.Addr) net.IP {
+	switch addr := a.(type) {
+	case *net.IPAddr:
+		if addr != nil {
+			return addr.IP
+		}
+	case *net.UDPAddr:
+		if addr != nil {
+			return addr.IP
+		}
 	}
-	return ipa.String() // Return IPv4 address as string.
+	return nil
 }
@@ -0,0 +1,819 @@
+// Copyright 2025 icmpkg Author. All Rights Reserved.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package icmpkg
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBudgetCounts(t *testing.T) {
+	tests := []struct {
+		total, hops int
+		want        []int
+	}{
+		{total: 0, hops: 0, want: []int{}},
+		{total: 2, hops: 4, want: []int{1, 1, 1, 1}},
+		{total: 10, hops: 4, want: []int{1, 2, 3, 4}},
+		{total: 12, hops: 4, want: []int{1, 2, 4, 5}},
+	}
+	for _, tt := range tests {
+		got := budgetCounts(tt.total, tt.hops)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("budgetCounts(%d, %d) = %v; want %v", tt.total, tt.hops, got, tt.want)
+		}
+		sum := 0
+		for _, n := range got {
+			sum += n
+		}
+		if tt.total >= tt.hops && sum != tt.total {
+			t.Errorf("budgetCounts(%d, %d) spends %d probes; want %d", tt.total, tt.hops, sum, tt.total)
+		}
+	}
+}
+
+func TestAip4(t *testing.T) {
+	var nilIP *net.IPAddr
+	var nilUDP *net.UDPAddr
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{nil, ""},
+		{nilIP, ""},
+		{nilUDP, ""},
+		{&net.IPAddr{IP: net.ParseIP("8.8.8.8")}, "8.8.8.8"},
+		{&net.UDPAddr{IP: net.ParseIP("8.8.8.8")}, "8.8.8.8"},
+		{&net.UDPAddr{IP: net.ParseIP("::ffff:1.1.1.1"), Port: 0}, "1.1.1.1"},
+		{&net.TCPAddr{IP: net.ParseIP("8.8.8.8")}, ""},
+	}
+	for _, tt := range tests {
+		if got := aip4(tt.addr); got != tt.want {
+			t.Errorf("aip4(%#v) = %q; want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		v6      bool
+	}{
+		{"127.0.0.1", "127.0.0.1", false},
+		{"::ffff:127.0.0.1", "127.0.0.1", false},
+		{"::1", "::1", true},
+	}
+	for _, tt := range tests {
+		tr := Ping(tt.address, 1)
+		if tr.Ip4() != tt.want || tr.IsV6() != tt.v6 {
+			t.Errorf("Ping(%q) resolved to %q, IsV6 %v; want %q, %v", tt.address, tr.Ip4(), tr.IsV6(), tt.want, tt.v6)
+		}
+	}
+}
+
+func TestAnnotateGeo(t *testing.T) {
+	tr := Ping("127.0.0.1", 1)
+	tr.GeoLookup(func(ip net.IP) (float64, float64, bool) {
+		if ip.Equal(net.ParseIP("8.8.8.8")) {
+			return 37.751, -97.822, true
+		}
+		return 0, 0, false
+	})
+	pto := pongProto(0, 1, 0, nil, "8.8.8.8", time.Millisecond)
+	tr.annotate(pto)
+	if !pto.Geo || pto.Lat != 37.751 || pto.Lon != -97.822 {
+		t.Errorf("annotate() geo = %v, %v, %v; want true, 37.751, -97.822", pto.Geo, pto.Lat, pto.Lon)
+	}
+	pto = pongProto(0, 1, 1, nil, "10.0.0.1", time.Millisecond)
+	if tr.annotate(pto); pto.Geo {
+		t.Error("annotate() should leave Geo unset when the lookup misses")
+	}
+}
+
+func TestIsBogon(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"127.0.0.1", true},
+		{"169.254.1.1", true},
+		{"203.0.113.5", true},
+		{"240.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		if got := IsBogon(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsBogon(%s) = %v; want %v", tt.ip, got, tt.want)
+		}
+	}
+	pto := pongProto(1, 1, 0, nil, "192.168.0.1", time.Millisecond)
+	if Ping("127.0.0.1", 1).annotate(pto); !pto.Private {
+		t.Error("annotate() should flag private hops")
+	}
+}
+
+func TestSlotDelay(t *testing.T) {
+	tr := TracerouteDuration("127.0.0.1", 4, 3, time.Second, time.Second)
+	tr.epoch = time.Unix(100, 0)
+	tests := []struct {
+		ttl     int
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{0, 0, 0},
+		{1, 0, 250 * time.Millisecond},
+		{3, 0, 750 * time.Millisecond},
+		{1, 100 * time.Millisecond, 150 * time.Millisecond},
+		{1, 300 * time.Millisecond, 950 * time.Millisecond},
+		{2, 2100 * time.Millisecond, 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := tr.slotDelay(tt.ttl, tr.epoch.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("slotDelay(%d, +%v) = %v; want %v", tt.ttl, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestRateDelay(t *testing.T) {
+	tr := PingDuration("127.0.0.1", 10, time.Second, time.Second)
+	tr.ReplyRate(4) // One reply every 250ms.
+	tr.started[0] = time.Unix(100, 0)
+	tests := []struct {
+		replies int
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{1, 10 * time.Millisecond, 240 * time.Millisecond},  // On schedule; a short RTT doesn't speed probes up.
+		{2, 300 * time.Millisecond, 200 * time.Millisecond}, // Wait for the third reply's slot.
+		{1, 600 * time.Millisecond, 0},                      // Lost a reply, or the RTT exceeded the period; catch up.
+	}
+	for _, tt := range tests {
+		tr.replies[0] = tt.replies
+		if got := tr.rateDelay(0, tr.started[0].Add(tt.elapsed)); got != tt.want {
+			t.Errorf("rateDelay() after %d replies at +%v = %v; want %v", tt.replies, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	tr := PingDuration("127.0.0.1", 3, time.Second, time.Second)
+	tr.Interval(100 * time.Millisecond)
+
+	tr.sent[0] = time.Now().Add(-time.Hour) // Long overdue: must not sleep, even though the remainder is negative.
+	start := time.Now()
+	tr.wait(0)
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("wait() after the pace elapsed slept %v", d)
+	}
+
+	tr.sent[0] = time.Now()
+	tr.wait(0)
+	if d := time.Since(tr.sent[0]); d < 100*time.Millisecond {
+		t.Errorf("wait() returned after %v; want the 100ms interval", d)
+	}
+}
+
+// skipWithoutRawSocket skips tests that need to send ICMP when raw sockets aren't permitted.
+func skipWithoutRawSocket(t *testing.T) {
+	t.Helper()
+	if err := CanRun(); err != nil {
+		t.Skipf("raw ICMP socket unavailable: %v", err)
+	}
+}
+
+func TestSetDefaultDurations(t *testing.T) {
+	defer SetDefaultWriteDuration(0)
+	defer SetDefaultReadDuration(0)
+	SetDefaultWriteDuration(time.Second)
+	SetDefaultReadDuration(2 * time.Second)
+	if p := Ping("127.0.0.1", 3); p.writeDur != time.Second || p.readDur != 2*time.Second {
+		t.Errorf("Ping durations = %v, %v; want 1s, 2s", p.writeDur, p.readDur)
+	}
+	if tr := Traceroute("127.0.0.1", 30, 3); tr.writeDur != time.Second || tr.readDur != 2*time.Second {
+		t.Errorf("Traceroute durations = %v, %v; want 1s, 2s", tr.writeDur, tr.readDur)
+	}
+	if p := PingFor("127.0.0.1", time.Minute, time.Second); p.readDur != time.Second {
+		t.Errorf("PingFor read duration = %v; want the 1s interval", p.readDur)
+	}
+
+	SetDefaultWriteDuration(0)
+	SetDefaultReadDuration(-time.Second)
+	if p := Ping("127.0.0.1", 3); p.writeDur != 500*time.Millisecond || p.readDur != 500*time.Millisecond {
+		t.Errorf("Ping durations after reset = %v, %v; want 500ms, 500ms", p.writeDur, p.readDur)
+	}
+}
+
+func TestPayloadData(t *testing.T) {
+	tr := Ping("127.0.0.1", 1)
+	data := []byte("sig")
+	tr.PayloadData(data)
+	data[0] = 'x' // The option keeps its own copy.
+	if pto := tr.probe(0, 7, 1); string(pto.data) != "sig" || tr.size != 3 {
+		t.Errorf("probe payload = %q, size %d; want \"sig\", 3", pto.data, tr.size)
+	}
+	tr.PayloadData(nil)
+	if pto := tr.probe(0, 7, 1); pto.data != nil {
+		t.Errorf("probe payload after PayloadData(nil) = %q; want none", pto.data)
+	}
+}
+
+func TestRunHandler(t *testing.T) {
+	skipWithoutRawSocket(t)
+	var pongs []*Proto
+	err := PingDuration("127.0.0.1", 2, time.Second, 100*time.Millisecond).RunHandler(func(pong *Proto) {
+		pongs = append(pongs, pong)
+	})
+	if err != nil || len(pongs) != 2 {
+		t.Errorf("RunHandler() = %v with %d pongs; want nil with 2", err, len(pongs))
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 4, time.Second, 100*time.Millisecond)
+	p.Interval(10 * time.Millisecond)
+	var pongs int32
+	p.PongHandler(func(pong *Proto) {
+		if atomic.AddInt32(&pongs, 1) == 2 {
+			p.Pause()
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if !p.Paused() || atomic.LoadInt32(&pongs) > 3 {
+		t.Fatalf("Paused() = %v after %d pongs; want probing held back", p.Paused(), atomic.LoadInt32(&pongs))
+	}
+	p.Resume()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run didn't finish after Resume")
+	}
+	if st := p.Stats(); st.Transmitted != 4 || p.Paused() {
+		t.Errorf("Stats().Transmitted = %d, Paused() = %v; want 4 probes after resuming", st.Transmitted, p.Paused())
+	}
+}
+
+func TestKernelTimestamps(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 3, time.Second, 100*time.Millisecond)
+	p.KernelTimestamps(true)
+	p.Interval(10 * time.Millisecond)
+	err := p.RunHandler(func(pong *Proto) {
+		if !pong.IsTimeout() && (pong.Rtt <= 0 || pong.Rtt > 100*time.Millisecond) {
+			t.Errorf("pong = %v; want a positive RTT", pong)
+		}
+	})
+	if err != nil {
+		t.Fatalf("RunHandler() error: %v", err)
+	}
+}
+
+func TestPayloadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.bin")
+	if err := os.WriteFile(path, []byte{0x00, 0xff, 'x'}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tr := Ping("127.0.0.1", 1)
+	if err := tr.PayloadFile(path); err != nil {
+		t.Fatalf("PayloadFile() error: %v", err)
+	}
+	if pto := tr.probe(0, 7, 1); !bytes.Equal(pto.data, []byte{0x00, 0xff, 'x'}) || tr.size != 3 {
+		t.Errorf("probe payload = %q, size %d; want the file's bytes verbatim", pto.data, tr.size)
+	}
+	if err := tr.PayloadFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("PayloadFile(missing) succeeded; want an error")
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	skipWithoutRawSocket(t)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// 192.0.2.0/24 is reserved for documentation, so the probes go unanswered.
+	p := PingDuration("192.0.2.123", 100, 100*time.Millisecond, 200*time.Millisecond)
+	p.Context(ctx)
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return within 1s of cancelling the context")
+	}
+	if got := p.Status(); got != StatusCancelled {
+		t.Errorf("Status() = %s; want %s", got, StatusCancelled)
+	}
+
+	// Allow the remaining goroutines to observe the stop before counting.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Errorf("goroutines leaked: %d before, %d after\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestRunStopRace(t *testing.T) {
+	skipWithoutRawSocket(t)
+	// Each case stops a run in a different way, racing the stop against the run's own shutdown.
+	cases := map[string]func(tr *traceroute, cancel context.CancelFunc){
+		"complete": func(tr *traceroute, cancel context.CancelFunc) {},
+		"stop": func(tr *traceroute, cancel context.CancelFunc) {
+			tr.Stop()
+		},
+		"overlapping stops": func(tr *traceroute, cancel context.CancelFunc) {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() { defer wg.Done(); tr.Stop() }()
+			}
+			wg.Wait()
+		},
+		"cancel and stop": func(tr *traceroute, cancel context.CancelFunc) {
+			go cancel()
+			tr.Stop()
+		},
+	}
+	for name, stop := range cases {
+		for _, traceroute := range []bool{false, true} {
+			for _, delay := range []time.Duration{0, time.Millisecond, 5 * time.Millisecond} {
+				ctx, cancel := context.WithCancel(context.Background())
+				tr := PingDuration("127.0.0.1", 5, time.Millisecond, 20*time.Millisecond)
+				if traceroute {
+					tr = TracerouteDuration("127.0.0.1", 3, 5, time.Millisecond, 20*time.Millisecond)
+				}
+				tr.Context(ctx)
+				tr.PongHandler(func(pong *Proto) {})
+				done := make(chan struct{})
+				go func() {
+					tr.Run()
+					close(done)
+				}()
+				time.Sleep(delay)
+				stop(tr, cancel)
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%s (traceroute %t, after %v): Run() did not return", name, traceroute, delay)
+				}
+				tr.Stop() // Stopping a finished run is a no-op.
+				cancel()
+				if tr.Status() == StatusNone {
+					t.Errorf("%s (traceroute %t, after %v): Status() = %s after Run returned", name, traceroute, delay, tr.Status())
+				}
+			}
+		}
+	}
+}
+
+func TestStopBeforeRun(t *testing.T) {
+	tr := Ping("127.0.0.1", 3)
+	var calls int32
+	tr.PongHandler(func(pong *Proto) { atomic.AddInt32(&calls, 1) })
+	tr.Stop()
+	tr.Run()
+	if got := tr.Status(); got != StatusStopped {
+		t.Errorf("Status() = %s; want %s", got, StatusStopped)
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Errorf("pong handler called %d times after Stop; want 0", got)
+	}
+}
+
+func TestResults(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 3, 20*time.Millisecond, 200*time.Millisecond)
+	var calls int32
+	p.PongHandler(func(pong *Proto) { atomic.AddInt32(&calls, 1) })
+	results := p.Results()
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	var got []*Proto
+	for pto := range results {
+		got = append(got, pto)
+	}
+	<-done
+	if len(got) != 3 {
+		t.Fatalf("Results() delivered %d Protos; want 3", len(got))
+	}
+	for i, pto := range got {
+		if pto.Seq != i {
+			t.Errorf("Results()[%d].Seq = %d; want %d", i, pto.Seq, i)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("pong handler called %d times alongside Results(); want 3", got)
+	}
+
+	// A stopped run closes the channel as well.
+	p.Reset(false)
+	results = p.Results()
+	p.Stop()
+	p.Run()
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Error("Results() delivered a Proto for a run stopped before it started")
+		}
+	case <-time.After(time.Second):
+		t.Error("Results() not closed after Stop")
+	}
+}
+
+func TestDestinationCount(t *testing.T) {
+	skipWithoutRawSocket(t)
+	// The loopback address answers at TTL 1, making it the destination's hop.
+	tr := TracerouteDuration("127.0.0.1", 3, 2, 20*time.Millisecond, 200*time.Millisecond)
+	tr.Interval(10 * time.Millisecond)
+	tr.DestinationCount(5)
+	var ttls []int
+	tr.PongHandler(func(pong *Proto) { ttls = append(ttls, pong.TTL) })
+	tr.Run()
+	if want := []int{1, 1, 1, 1, 1}; !reflect.DeepEqual(ttls, want) {
+		t.Errorf("pong handler saw TTLs %v; want %v", ttls, want)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	skipWithoutRawSocket(t)
+	// 192.0.2.0/24 is reserved for documentation, so no hop answers and every probe times out.
+	tr := TracerouteDuration("192.0.2.123", 5, 2, 100*time.Millisecond, 100*time.Millisecond)
+	tr.Parallel(true)
+	var ttls []int
+	tr.PongHandler(func(pong *Proto) { ttls = append(ttls, pong.TTL) })
+	start := time.Now()
+	tr.Run()
+	// Serially, the first probes alone take 5 timeouts; in parallel all TTLs time out together.
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("parallel traceroute took %v; want about two read durations", d)
+	}
+	want := []int{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
+	if !reflect.DeepEqual(ttls, want) {
+		t.Errorf("pong handler saw TTLs %v; want %v", ttls, want)
+	}
+
+	// The loopback address answers at TTL 1, so the hops past it are dropped.
+	tr = TracerouteDuration("127.0.0.1", 3, 2, 20*time.Millisecond, 200*time.Millisecond)
+	tr.Parallel(true)
+	ttls = nil
+	tr.PongHandler(func(pong *Proto) { ttls = append(ttls, pong.TTL) })
+	tr.Run()
+	if want := []int{1, 1}; !reflect.DeepEqual(ttls, want) {
+		t.Errorf("pong handler saw TTLs %v; want %v", ttls, want)
+	}
+}
+
+func TestUniform(t *testing.T) {
+	skipWithoutRawSocket(t)
+	// 192.0.2.0/24 is reserved for documentation, so no hop answers and every probe times out.
+	tr := TracerouteDuration("192.0.2.123", 5, 2, 100*time.Millisecond, 100*time.Millisecond)
+	tr.Uniform(true)
+	perTTL := map[int]int{}
+	tr.PongHandler(func(pong *Proto) { perTTL[pong.TTL]++ })
+	start := time.Now()
+	tr.Run()
+	// No TTL waits for the first probe of the previous one.
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("uniform traceroute took %v; want about two read durations", d)
+	}
+	if want := map[int]int{1: 2, 2: 2, 3: 2, 4: 2, 5: 2}; !reflect.DeepEqual(perTTL, want) {
+		t.Errorf("pong handler saw probes per TTL %v; want %v", perTTL, want)
+	}
+
+	p := PingDuration("127.0.0.1", 3, 20*time.Millisecond, 200*time.Millisecond)
+	p.Uniform(true)
+	var seqs []int
+	p.PongHandler(func(pong *Proto) { seqs = append(seqs, pong.Seq) })
+	p.Run()
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(seqs, want) {
+		t.Errorf("pong handler saw seqs %v; want %v", seqs, want)
+	}
+}
+
+func TestContinuousPing(t *testing.T) {
+	skipWithoutRawSocket(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	p := PingDuration("127.0.0.1", 0, 10*time.Millisecond, 100*time.Millisecond)
+	p.Interval(10 * time.Millisecond)
+	p.Context(ctx)
+	var handled int32
+	p.PongHandler(func(pong *Proto) { atomic.AddInt32(&handled, 1) })
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() with count 0 did not return after the context was cancelled")
+	}
+	if got := p.Status(); got != StatusCancelled {
+		t.Errorf("Status() = %s; want %s", got, StatusCancelled)
+	}
+	st := p.Stats()
+	if st.Transmitted < 5 {
+		t.Errorf("Stats().Transmitted = %d; want pings to keep going until cancelled", st.Transmitted)
+	}
+	if got := int(atomic.LoadInt32(&handled)); got == 0 || got > st.Transmitted {
+		t.Errorf("pong handler called %d times for %d probes", got, st.Transmitted)
+	}
+}
+
+func TestReadTTLLateGrace(t *testing.T) {
+	tr := PingDuration("127.0.0.1", 1, 10*time.Millisecond, 20*time.Millisecond)
+	tr.ic[0] = make(chan *Proto, 1)
+	tr.hc = make(chan *Proto, 2) // Out of order replies are handled while readTTL waits.
+	reply := func(seq int, after time.Duration) {
+		time.AfterFunc(after, func() { tr.ic[0] <- pongProto(0, 7, seq, nil, "127.0.0.1", after) })
+	}
+
+	reply(0, 5*time.Millisecond)
+	if pto := tr.readTTL(0, 7, 0, tr.handler); pto.IsTimeout() || pto.Late {
+		t.Errorf("reply within the read timeout = %s, late %t; want an on-time reply", pto, pto.Late)
+	}
+
+	reply(1, 40*time.Millisecond)
+	if pto := tr.readTTL(0, 7, 1, tr.handler); !pto.IsTimeout() {
+		t.Errorf("reply after the read timeout without grace = %s; want a timeout", pto)
+	}
+
+	tr.LateGrace(50 * time.Millisecond)
+	reply(2, 40*time.Millisecond) // Reaches the channel after the read timeout but within the grace window.
+	pto := tr.readTTL(0, 7, 2, tr.handler)
+	if pto.IsTimeout() || !pto.Late || pto.Seq != 2 {
+		t.Errorf("reply within the grace window = %s, late %t; want a late reply to seq 2", pto, pto.Late)
+	}
+
+	// The reply to seq 1 that arrived too late must not be credited to seq 3.
+	tr.ic[0] <- pongProto(0, 7, 1, nil, "127.0.0.1", time.Millisecond)
+	if pto := tr.readTTL(0, 7, 3, tr.handler); !pto.IsTimeout() {
+		t.Errorf("readTTL(seq 3) = %s; want the stale reply to seq 1 left out", pto)
+	}
+	// Both late replies to seq 1 were handled as out of order instead.
+	for i := 0; i < 2; i++ {
+		if pto := <-tr.hc; !pto.OutOfOrder || pto.Seq != 1 {
+			t.Errorf("handled reply %d = %s, out of order %t; want an out of order reply to seq 1", i, pto, pto.OutOfOrder)
+		}
+	}
+	if s := tr.Stats(); s.OutOfOrder != 2 || s.Received != 0 {
+		t.Errorf("OutOfOrder, Received = %d, %d; want 2, 0", s.OutOfOrder, s.Received)
+	}
+}
+
+func TestReadTTLQueuesExtras(t *testing.T) {
+	tr := TracerouteDuration("127.0.0.1", 3, 1, 10*time.Millisecond, 20*time.Millisecond)
+	tr.ic[0] = make(chan *Proto, 2)
+	dup := pongProto(1, 7, 0, nil, "10.0.0.1", time.Millisecond)
+	dup.Dup = true
+	tr.ic[0] <- dup
+	tr.ic[0] <- pongProto(1, 7, 1, nil, "10.0.0.1", time.Millisecond)
+
+	// With Parallel, a TTL's duplicates are queued with its own results rather than handled at once.
+	queue := newHopQueue()
+	pto := tr.readTTL(0, 7, 1, queue.push)
+	queue.push(pto)
+	queue.close()
+	var got []*Proto
+	for pto, ok := queue.pop(); ok; pto, ok = queue.pop() {
+		got = append(got, pto)
+	}
+	if len(got) != 2 || !got[0].Dup || got[1].Dup || got[1].Seq != 1 {
+		t.Fatalf("queued %v; want the duplicate, then the reply to seq 1", got)
+	}
+	if s := tr.Stats(); s.Transmitted != 0 || s.Duplicates != 0 {
+		t.Errorf("Stats() = %+v; want nothing handled before the queue is drained", s)
+	}
+}
+
+func TestPongDropsUnreadDuplicates(t *testing.T) {
+	tr := TracerouteDuration("127.0.0.1", 3, 1, 10*time.Millisecond, 10*time.Millisecond)
+	tr.ic[0] = make(chan *Proto, 1)
+	tr.setHop(7, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			dup := pongProto(1, 7, 0, nil, "10.0.0.1", time.Millisecond)
+			dup.Dup = true
+			tr.pong(dup) // Nothing reads the TTL anymore.
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pong() blocked on duplicates to a TTL nobody reads")
+	}
+}
+
+func TestPongDropsUnreadLateReplies(t *testing.T) {
+	tr := TracerouteDuration("127.0.0.1", 3, 1, 10*time.Millisecond, 10*time.Millisecond)
+	tr.ic[0] = make(chan *Proto, 1)
+	tr.setHop(7, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seq := 0; seq < 3; seq++ {
+			tr.pong(pongProto(1, 7, seq, nil, "10.0.0.1", time.Second)) // Late replies after the last probe.
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pong() blocked on late replies to a TTL nobody reads")
+	}
+	if pto := <-tr.ic[0]; pto.Seq != 2 {
+		t.Errorf("queued reply = %s; want the newest, to seq 2", pto)
+	}
+}
+
+func TestReset(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 2, 50*time.Millisecond, 50*time.Millisecond)
+	p.Run()
+	p.Reset(true)
+	p.Run()
+	if got := p.Stats().Transmitted; got != 4 {
+		t.Errorf("Stats().Transmitted after Reset(true) = %d; want 4", got)
+	}
+	if p.Status() != StatusCompleted {
+		t.Errorf("Status() = %s; want %s", p.Status(), StatusCompleted)
+	}
+	p.Reset(false)
+	if got := p.Stats(); got != (Statistics{}) {
+		t.Errorf("Stats() after Reset(false) = %+v; want zero", got)
+	}
+	if p.Status() != StatusNone {
+		t.Errorf("Status() after Reset = %s; want %s", p.Status(), StatusNone)
+	}
+	p.Run()
+	if got := p.Stats().Transmitted; got != 2 {
+		t.Errorf("Stats().Transmitted after Reset(false) = %d; want 2", got)
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	skipWithoutRawSocket(t)
+	release := make(chan struct{})
+	defer close(release)
+	p := PingDuration("127.0.0.1", 3, 50*time.Millisecond, 50*time.Millisecond)
+	p.HandlerTimeout(20 * time.Millisecond)
+	var calls int32
+	p.PongHandler(func(pong *Proto) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release // The first call blocks until the test ends.
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() stalled behind a blocked pong handler")
+	}
+	if got := p.HandlerTimeouts(); got != 1 {
+		t.Errorf("HandlerTimeouts() = %d; want 1", got)
+	}
+	if got := atomic.LoadInt32(&calls); got < 2 {
+		t.Errorf("pong handler called %d times; want it called again after the stall", got)
+	}
+}
+
+func TestInterfaceIndex(t *testing.T) {
+	ifaces, _ := net.Interfaces()
+	var lo *net.Interface
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagLoopback != 0 {
+			lo = &ifaces[i]
+		}
+	}
+	if lo == nil {
+		t.Skip("no loopback interface")
+	}
+	tr := Traceroute("127.0.0.1", 3, 1)
+	if err := tr.InterfaceIndex(lo.Index); err != nil {
+		t.Fatalf("InterfaceIndex(%d) error: %v", lo.Index, err)
+	}
+	if !tr.source.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("source = %v; want 127.0.0.1", tr.source)
+	}
+	if err := tr.Interface(lo.Name); err != nil || !tr.source.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Interface(%q) = %v, source %v; want 127.0.0.1", lo.Name, err, tr.source)
+	}
+	if err := tr.InterfaceIndex(1 << 20); err == nil {
+		t.Error("InterfaceIndex() of a missing interface returned no error")
+	}
+}
+
+func TestSourceAddress(t *testing.T) {
+	tr := Ping("127.0.0.1", 1)
+	if err := tr.SourceAddress("127.0.0.1"); err != nil || !tr.source.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("SourceAddress(127.0.0.1) = %v, source %v; want 127.0.0.1", err, tr.source)
+	}
+	for _, ip := range []string{"192.0.2.1", "::1", "eth0"} {
+		if err := tr.SourceAddress(ip); err == nil {
+			t.Errorf("SourceAddress(%q) returned no error", ip)
+		}
+	}
+}
+
+func TestTOS(t *testing.T) {
+	tr := Ping("127.0.0.1", 1)
+	if err := tr.TOS(184); err != nil || tr.tos != 184 {
+		t.Errorf("TOS(184) = %v, tos %d; want 184", err, tr.tos)
+	}
+	for _, tos := range []int{-1, 256} {
+		if err := tr.TOS(tos); err == nil {
+			t.Errorf("TOS(%d) returned no error", tos)
+		}
+	}
+}
+
+func TestConcurrentPingTraceroute(t *testing.T) {
+	skipWithoutRawSocket(t)
+	p := PingDuration("127.0.0.1", 5, 20*time.Millisecond, 200*time.Millisecond)
+	tr := TracerouteDuration("127.0.0.1", 3, 5, 20*time.Millisecond, 200*time.Millisecond)
+	// Each run records the IDs of the replies it was handed.
+	pingIds, trIds := map[int]int{}, map[int]int{}
+	var mu sync.Mutex
+	p.PongHandler(func(pong *Proto) {
+		mu.Lock()
+		defer mu.Unlock()
+		pingIds[pong.ID]++
+	})
+	tr.PongHandler(func(pong *Proto) {
+		mu.Lock()
+		defer mu.Unlock()
+		trIds[pong.ID]++
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); p.Run() }()
+	go func() { defer wg.Done(); tr.Run() }()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id := range pingIds {
+		if trIds[id] > 0 {
+			t.Errorf("ICMP ID %d seen by both the ping and the traceroute", id)
+		}
+	}
+	if len(pingIds) != 1 {
+		t.Errorf("ping saw replies for IDs %v; want its own ID only", pingIds)
+	}
+	for name, s := range map[string]Statistics{"ping": p.Stats(), "traceroute": tr.Stats()} {
+		if s.Received != s.Transmitted || s.Received == 0 {
+			t.Errorf("%s received %d of %d probes; want all of them", name, s.Received, s.Transmitted)
+		}
+	}
+}